@@ -0,0 +1,118 @@
+package codegen
+
+import (
+	"bytes"
+	"os"
+
+	"github.com/go-courier/codegen/formatx"
+)
+
+type CheckResult struct {
+	Diffs []*FileDiff
+}
+
+func (r *CheckResult) Passed() bool {
+	return len(r.Diffs) == 0
+}
+
+func (r *CheckResult) String() string {
+	buf := &bytes.Buffer{}
+
+	for _, d := range r.Diffs {
+		buf.WriteString(d.Diff)
+	}
+
+	return buf.String()
+}
+
+type FileDiff struct {
+	Filename string
+	Diff     string
+}
+
+func (file *File) Check() (*CheckResult, error) {
+	result := &CheckResult{}
+
+	data, err := file.Render()
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := diffFile(file.outputFS(), file.filename, data, true)
+	if err != nil {
+		return nil, err
+	}
+	if d != nil {
+		result.Diffs = append(result.Diffs, d)
+	}
+
+	return result, nil
+}
+
+func (p *Project) Check() (*CheckResult, error) {
+	result := &CheckResult{}
+
+	files := p.Files()
+	results := make([]*CheckResult, len(files))
+
+	err := parallel(p.workers, len(files), func(i int) (err error) {
+		results[i], err = files[i].Check()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range results {
+		result.Diffs = append(result.Diffs, r.Diffs...)
+	}
+
+	staleFiles, err := p.staleFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, filename := range staleFiles {
+		d, err := diffFile(p.outputFS(), filename, nil, false)
+		if err != nil {
+			return nil, err
+		}
+		result.Diffs = append(result.Diffs, d)
+	}
+
+	return result, nil
+}
+
+func diffFile(fs OutputFS, filename string, data []byte, exists bool) (*FileDiff, error) {
+	existing, err := fs.ReadFile(filename)
+	existed := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if existed == exists && bytes.Equal(existing, data) {
+		return nil, nil
+	}
+
+	fromFile, toFile := filename, filename
+
+	if !existed {
+		existing = nil
+		fromFile = os.DevNull
+	}
+
+	if !exists {
+		data = nil
+		toFile = os.DevNull
+	}
+
+	diff, err := formatx.UnifiedDiff(fromFile, toFile, existing, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileDiff{
+		Filename: filename,
+		Diff:     diff,
+	}, nil
+}
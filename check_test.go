@@ -0,0 +1,100 @@
+package codegen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFile_Check(t *testing.T) {
+	tt := require.New(t)
+
+	filename := filepath.Join(t.TempDir(), "check.go")
+
+	newFile := func(value string) *File {
+		file := NewFile("check", filename)
+		file.WriteBlock(DeclConst(Assign(Id("Value")).By(Val(value))))
+		return file
+	}
+
+	result, err := newFile("a").Check()
+	tt.NoError(err)
+	tt.False(result.Passed())
+	tt.Equal(`--- `+os.DevNull+`
++++ `+filename+`
+@@ -0,0 +1,3 @@
++package check
++
++const Value = "a"
+`, result.String())
+
+	_, err = newFile("a").WriteFile()
+	tt.NoError(err)
+
+	result, err = newFile("a").Check()
+	tt.NoError(err)
+	tt.True(result.Passed())
+
+	result, err = newFile("b").Check()
+	tt.NoError(err)
+	tt.False(result.Passed())
+	tt.Equal(`--- `+filename+`
++++ `+filename+`
+@@ -1,3 +1,3 @@
+ package check
+ 
+-const Value = "a"
++const Value = "b"
+`, result.Diffs[0].Diff)
+}
+
+func TestProject_Check(t *testing.T) {
+	tt := require.New(t)
+
+	dir := t.TempDir()
+
+	tt.NoError(os.WriteFile(filepath.Join(dir, "stale__generated.go"), []byte("// Code generated by gen. DO NOT EDIT.\n\npackage pkg\n"), 0644))
+
+	p := NewProject("gen")
+	p.NewFile("pkg", filepath.Join(dir, "a__generated.go")).WriteBlock(DeclConst(Assign(Id("A")).By(Val(1))))
+
+	result, err := p.Check()
+	tt.NoError(err)
+	tt.False(result.Passed())
+	tt.Len(result.Diffs, 2)
+	tt.Equal(filepath.Join(dir, "stale__generated.go"), result.Diffs[1].Filename)
+	tt.Equal(`--- `+filepath.Join(dir, "stale__generated.go")+`
++++ `+os.DevNull+`
+@@ -1,3 +0,0 @@
+-// Code generated by gen. DO NOT EDIT.
+-
+-package pkg
+`, result.Diffs[1].Diff)
+
+	_, err = p.WriteFiles()
+	tt.NoError(err)
+
+	result, err = p.Check()
+	tt.NoError(err)
+	tt.True(result.Passed())
+}
+
+func TestProject_Check_OtherGenerators(t *testing.T) {
+	tt := require.New(t)
+
+	dir := t.TempDir()
+
+	tt.NoError(os.WriteFile(filepath.Join(dir, "enum__generated.go"), []byte("// Code generated by enumgen. DO NOT EDIT.\n\npackage pkg\n"), 0644))
+
+	p := NewProject("gen").WithResolver(StaticPackageResolver{})
+	p.NewFile("pkg", filepath.Join(dir, "a__generated.go")).WriteBlock(DeclConst(Assign(Id("A")).By(Val(1))))
+
+	_, err := p.WriteFiles()
+	tt.NoError(err)
+
+	result, err := p.Check()
+	tt.NoError(err)
+	tt.True(result.Passed())
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-courier/codegen/formatx"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+type formatter struct {
+	list      bool
+	write     bool
+	diff      bool
+	processes []formatx.Process
+	stdout    io.Writer
+	stderr    io.Writer
+	failed    bool
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("codegen-fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: codegen-fmt [flags] [path ...]\n")
+		flags.PrintDefaults()
+	}
+
+	f := &formatter{stdout: stdout, stderr: stderr}
+
+	flags.BoolVar(&f.list, "l", false, "list files whose formatting differs from codegen-fmt's")
+	flags.BoolVar(&f.write, "w", false, "write result to (source) file instead of stdout")
+	flags.BoolVar(&f.diff, "d", false, "display diffs instead of rewriting files")
+	local := flags.String("local", "", "put imports beginning with this string after 3rd-party packages; comma-separated list")
+	fix := flags.Bool("fix", false, "add missing and remove unused imports")
+	strict := flags.Bool("strict", false, "apply stricter gofumpt-style formatting")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *fix {
+		f.processes = append(f.processes, formatx.FixImportsProcess)
+	}
+
+	if *local != "" {
+		f.processes = append(f.processes, formatx.SortImportsWithLocalPrefixes(strings.Split(*local, ",")...))
+	} else {
+		f.processes = append(f.processes, formatx.SortImportsProcess)
+	}
+
+	if *strict {
+		f.processes = append(f.processes, formatx.StrictProcesses...)
+	}
+
+	if flags.NArg() == 0 {
+		if f.write {
+			fmt.Fprintln(stderr, "error: cannot use -w with standard input")
+			return 2
+		}
+		cwd, _ := os.Getwd()
+		f.processFile(filepath.Join(cwd, "<standard input>.go"), stdin, "<standard input>")
+	}
+
+	for _, arg := range flags.Args() {
+		info, err := os.Stat(arg)
+		if err != nil {
+			f.report(err)
+			continue
+		}
+		if info.IsDir() {
+			f.walkDir(arg)
+			continue
+		}
+		f.processFile(arg, nil, arg)
+	}
+
+	if f.failed {
+		return 2
+	}
+	return 0
+}
+
+func (f *formatter) report(err error) {
+	fmt.Fprintln(f.stderr, err)
+	f.failed = true
+}
+
+func (f *formatter) walkDir(dir string) {
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			f.report(err)
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") || !strings.HasSuffix(d.Name(), ".go") {
+			return nil
+		}
+		f.processFile(path, nil, path)
+		return nil
+	})
+}
+
+func (f *formatter) processFile(filename string, in io.Reader, displayName string) {
+	var src []byte
+	var err error
+
+	if in == nil {
+		src, err = os.ReadFile(filename)
+	} else {
+		src, err = io.ReadAll(in)
+	}
+	if err != nil {
+		f.report(err)
+		return
+	}
+
+	filename, err = filepath.Abs(filename)
+	if err != nil {
+		f.report(err)
+		return
+	}
+
+	res, err := formatx.Format(filename, src, f.processes...)
+	if err != nil {
+		f.report(err)
+		return
+	}
+
+	if !f.list && !f.write && !f.diff {
+		_, _ = f.stdout.Write(res)
+		return
+	}
+
+	if bytes.Equal(src, res) {
+		return
+	}
+
+	if f.list {
+		fmt.Fprintln(f.stdout, displayName)
+	}
+
+	if f.write {
+		info, err := os.Stat(filename)
+		if err != nil {
+			f.report(err)
+			return
+		}
+		if err := os.WriteFile(filename, res, info.Mode().Perm()); err != nil {
+			f.report(err)
+			return
+		}
+	}
+
+	if f.diff {
+		d, err := formatx.UnifiedDiff(filepath.ToSlash(filepath.Join("a", displayName)), filepath.ToSlash(filepath.Join("b", displayName)), src, res)
+		if err != nil {
+			f.report(err)
+			return
+		}
+		fmt.Fprintf(f.stdout, "diff %s codegen-fmt/%s\n%s", displayName, displayName, d)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const unformatted = `package pkg
+
+import (
+	"example.com/pkg/sub"
+	"github.com/go-courier/codegen"
+	"fmt"
+)
+
+var _ = fmt.Sprint(codegen.Id, sub.Value)
+`
+
+const formatted = `package pkg
+
+import (
+	"fmt"
+
+	"github.com/go-courier/codegen"
+
+	"example.com/pkg/sub"
+)
+
+var _ = fmt.Sprint(codegen.Id, sub.Value)
+`
+
+func setup(t *testing.T) (string, string) {
+	dir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/pkg\n\ngo 1.22\n"), 0644))
+
+	filename := filepath.Join(dir, "pkg", "a.go")
+	require.NoError(t, os.MkdirAll(filepath.Dir(filename), os.ModePerm))
+	require.NoError(t, os.WriteFile(filename, []byte(unformatted), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pkg", "b.go"), []byte(formatted), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".hidden"), os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".hidden", "c.go"), []byte(unformatted), 0644))
+
+	return dir, filename
+}
+
+func TestRun(t *testing.T) {
+	t.Run("stdout", func(t *testing.T) {
+		tt := require.New(t)
+		_, filename := setup(t)
+
+		stdout := &bytes.Buffer{}
+		tt.Equal(0, run([]string{filename}, nil, stdout, os.Stderr))
+		tt.Equal(formatted, stdout.String())
+	})
+
+	t.Run("stdin", func(t *testing.T) {
+		tt := require.New(t)
+		dir, _ := setup(t)
+
+		cwd, err := os.Getwd()
+		tt.NoError(err)
+		tt.NoError(os.Chdir(filepath.Join(dir, "pkg")))
+		t.Cleanup(func() {
+			_ = os.Chdir(cwd)
+		})
+
+		stdout := &bytes.Buffer{}
+		tt.Equal(0, run(nil, strings.NewReader(unformatted), stdout, os.Stderr))
+		tt.Equal(formatted, stdout.String())
+	})
+
+	t.Run("list", func(t *testing.T) {
+		tt := require.New(t)
+		dir, filename := setup(t)
+
+		stdout := &bytes.Buffer{}
+		tt.Equal(0, run([]string{"-l", dir}, nil, stdout, os.Stderr))
+		tt.Equal(filename+"\n", stdout.String())
+	})
+
+	t.Run("diff", func(t *testing.T) {
+		tt := require.New(t)
+		dir, filename := setup(t)
+
+		stdout := &bytes.Buffer{}
+		tt.Equal(0, run([]string{"-d", dir}, nil, stdout, os.Stderr))
+		tt.Equal(`diff `+filename+` codegen-fmt/`+filename+`
+--- `+filepath.ToSlash(filepath.Join("a", filename))+`
++++ `+filepath.ToSlash(filepath.Join("b", filename))+`
+@@ -1,9 +1,11 @@
+ package pkg
+ 
+ import (
++	"fmt"
++
++	"github.com/go-courier/codegen"
++
+ 	"example.com/pkg/sub"
+-	"github.com/go-courier/codegen"
+-	"fmt"
+ )
+ 
+ var _ = fmt.Sprint(codegen.Id, sub.Value)
+`, stdout.String())
+	})
+
+	t.Run("write", func(t *testing.T) {
+		tt := require.New(t)
+		dir, filename := setup(t)
+
+		stdout := &bytes.Buffer{}
+		tt.Equal(0, run([]string{"-w", dir}, nil, stdout, os.Stderr))
+		tt.Empty(stdout.String())
+
+		data, err := os.ReadFile(filename)
+		tt.NoError(err)
+		tt.Equal(formatted, string(data))
+
+		data, err = os.ReadFile(filepath.Join(dir, ".hidden", "c.go"))
+		tt.NoError(err)
+		tt.Equal(unformatted, string(data))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tt := require.New(t)
+
+		stderr := &bytes.Buffer{}
+		tt.Equal(2, run([]string{"-w"}, strings.NewReader(unformatted), os.Stdout, stderr))
+		tt.Equal(2, run([]string{"not-exists.go"}, nil, os.Stdout, stderr))
+		tt.Equal(2, run(nil, strings.NewReader("package"), os.Stdout, stderr))
+	})
+}
@@ -3,15 +3,19 @@ package codegen
 import (
 	"bytes"
 	"fmt"
-	"io"
-	"os"
+	"go/ast"
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"unicode"
 
 	"github.com/go-courier/codegen/formatx"
-	"golang.org/x/tools/go/packages"
 )
 
 func NewFile(pkgName string, filename string) *File {
@@ -22,32 +26,224 @@ func NewFile(pkgName string, filename string) *File {
 }
 
 type File struct {
-	PkgName  string
-	filename string
-	imports  map[string]string
-	bytes.Buffer
+	PkgName        string
+	filename       string
+	header         SnippetComments
+	pkgDoc         SnippetComments
+	generator      string
+	pkgPath        *string
+	buildExpr      constraint.Expr
+	resolver       PackageResolver
+	typeCheck      bool
+	lineDirectives bool
+	fs             OutputFS
+	processes      []formatx.Process
+	imports        map[string]string
+	pkgNames       map[string]string
+	importSpecs    []*SnippetImport
+	errs           []error
+	mu             sync.Mutex
+	pkgPathOnce    sync.Once
+	buf            bytes.Buffer
+}
+
+func (file *File) WithHeader(lines ...string) *File {
+	file.header = Comments(lines...)
+	return file
+}
+
+func (file *File) WithPackageDoc(lines ...string) *File {
+	file.pkgDoc = Comments(lines...)
+	return file
+}
+
+func (file *File) WithGenerator(generator string) *File {
+	file.generator = generator
+	return file
+}
+
+func (file *File) WithBuildConstraint(expr string) *File {
+	file.mu.Lock()
+	defer file.mu.Unlock()
+
+	x, err := constraint.Parse("//go:build " + expr)
+	if err != nil {
+		file.errs = append(file.errs, fmt.Errorf("invalid build constraint `%s`: %w", expr, err))
+		return file
+	}
+	if file.buildExpr != nil {
+		x = &constraint.AndExpr{X: file.buildExpr, Y: x}
+	}
+	file.buildExpr = x
+	return file
+}
+
+func (file *File) WithPkgPath(pkgPath string) *File {
+	file.pkgPath = &pkgPath
+	return file
+}
+
+func (file *File) PkgPath() string {
+	file.pkgPathOnce.Do(func() {
+		if file.pkgPath == nil {
+			pkgPath := ""
+			if !strings.HasSuffix(file.PkgName, "_test") {
+				pkgPath, _ = pkgPathOfDir(filepath.Dir(file.filename))
+			}
+			file.pkgPath = &pkgPath
+		}
+	})
+	return *file.pkgPath
+}
+
+func (file *File) WithTypeCheck(typeCheck bool) *File {
+	file.typeCheck = typeCheck
+	return file
+}
+
+func (file *File) WithLineDirectives(lineDirectives bool) *File {
+	file.lineDirectives = lineDirectives
+	return file
+}
+
+func (file *File) WithFS(fs OutputFS) *File {
+	file.fs = fs
+	return file
+}
+
+func (file *File) WithProcesses(processes ...formatx.Process) *File {
+	file.processes = append(file.processes, processes...)
+	return file
+}
+
+func (file *File) outputFS() OutputFS {
+	if file.fs == nil {
+		return OSFS{}
+	}
+	return file.fs
+}
+
+func (file *File) WithResolver(resolver PackageResolver) *File {
+	file.resolver = resolver
+	return file
 }
 
 func (file *File) WriteBlock(ss ...Snippet) {
+	buf := &bytes.Buffer{}
+
 	for _, s := range ss {
-		file.Write(s.Bytes())
-		file.WriteString("\n\n")
+		buf.Write(s.Bytes())
+		buf.WriteString("\n\n")
 	}
+
+	file.Write(buf.Bytes())
+}
+
+func (file *File) Write(p []byte) (int, error) {
+	file.mu.Lock()
+	defer file.mu.Unlock()
+
+	return file.buf.Write(p)
+}
+
+func (file *File) WriteString(s string) (int, error) {
+	file.mu.Lock()
+	defer file.mu.Unlock()
+
+	return file.buf.WriteString(s)
 }
 
 func (file *File) Bytes() []byte {
+	data, err := file.Render()
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (file *File) Render() ([]byte, error) {
+	data, _, err := file.RenderWithSourceMap()
+	return data, err
+}
+
+func (file *File) RenderWithSourceMap() ([]byte, SourceMap, error) {
+	file.mu.Lock()
+	if len(file.errs) > 0 {
+		err := file.errs[0]
+		file.mu.Unlock()
+		return nil, nil, &FileError{Filename: file.filename, Err: err}
+	}
+	src := file.source()
+	file.mu.Unlock()
+
+	data, err := formatx.Format(file.filename, src, append([]formatx.Process{formatx.SortImportsProcess}, file.processes...)...)
+	if err != nil {
+		return nil, nil, newFileError(file.filename, src, err)
+	}
+
+	data, sourceMap, err := resolveOrigins(file.filename, data, file.lineDirectives)
+	if err != nil {
+		return nil, nil, &FileError{Filename: file.filename, Err: err}
+	}
+
+	return data, sourceMap, nil
+}
+
+func (file *File) source() []byte {
 	buf := &bytes.Buffer{}
 
+	if len(file.header) > 0 {
+		buf.Write(file.header.Bytes())
+		buf.WriteString("\n")
+	}
+
+	if file.generator != "" {
+		buf.WriteString(GeneratedHeader(file.generator))
+		buf.WriteString("\n\n")
+	}
+
+	if file.buildExpr != nil {
+		buf.WriteString("//go:build ")
+		buf.WriteString(file.buildExpr.String())
+		buf.WriteString("\n\n")
+	}
+
+	if len(file.pkgDoc) > 0 {
+		buf.Write(file.pkgDoc.Bytes())
+	}
+
 	buf.WriteString(`package ` + LowerSnakeCase(file.PkgName) + `
 `)
 
-	if file.imports != nil {
+	if file.imports != nil || file.importSpecs != nil {
 		buf.WriteString(`import (
 `)
+		specs := append([]*SnippetImport{}, file.importSpecs...)
+
+		explicit := map[string]bool{}
+		for _, spec := range file.importSpecs {
+			if spec.Alias != "_" {
+				explicit[spec.Path] = true
+			}
+		}
+
 		for importPath, alias := range file.imports {
-			buf.WriteString(alias)
-			buf.WriteString(" ")
-			buf.WriteString(strconv.Quote(importPath))
+			if explicit[importPath] {
+				continue
+			}
+			if alias == file.pkgNames[importPath] {
+				specs = append(specs, Import(importPath))
+			} else {
+				specs = append(specs, Import(importPath).As(alias))
+			}
+		}
+
+		sort.SliceStable(specs, func(i, j int) bool {
+			return specs[i].Path < specs[j].Path
+		})
+
+		for _, spec := range specs {
+			buf.Write(spec.Bytes())
 			buf.WriteString("\n")
 		}
 
@@ -55,9 +251,9 @@ func (file *File) Bytes() []byte {
 `)
 	}
 
-	io.Copy(buf, &file.Buffer)
+	buf.Write(file.buf.Bytes())
 
-	return formatx.MustFormat(file.filename, buf.Bytes(), formatx.SortImportsProcess)
+	return buf.Bytes()
 }
 
 func (file *File) Expr(f string, args ...interface{}) SnippetExpr {
@@ -72,26 +268,185 @@ func (file *File) Val(v interface{}) Snippet {
 	return createVal(file.importAliaser)(v)
 }
 
+// Import registers imports up front. The first package to claim a name keeps it and later clashing
+// ones get path-derived aliases, so when snippets are built from several goroutines, import packages
+// with clashing names here first to keep the output deterministic.
+func (file *File) Import(specs ...*SnippetImport) {
+	for _, spec := range specs {
+		alias := ""
+		if spec.Alias == "" {
+			alias = file.importAliaser(spec.Path)
+		}
+		file.addImport(spec, alias)
+	}
+}
+
+func (file *File) addImport(spec *SnippetImport, alias string) {
+	file.mu.Lock()
+	defer file.mu.Unlock()
+
+	file.initImports()
+
+	if existing, ok := file.imports[spec.Path]; ok && !spec.keepsAlias(existing) {
+		file.errs = append(file.errs, fmt.Errorf("import `%s` as `%s` conflicts with its existing alias `%s`", spec.Path, spec.Alias, existing))
+		return
+	}
+
+	switch spec.Alias {
+	case "_":
+	case ".":
+		file.imports[spec.Path] = ""
+	case "":
+		if alias != file.pkgNames[spec.Path] {
+			spec = spec.As(alias)
+		}
+	default:
+		for importPath, used := range file.imports {
+			if used == spec.Alias && importPath != spec.Path {
+				file.errs = append(file.errs, fmt.Errorf("import alias `%s` of `%s` is already used by `%s`", used, spec.Path, importPath))
+			}
+		}
+		file.imports[spec.Path] = spec.Alias
+	}
+	file.importSpecs = append(file.importSpecs, spec)
+}
+
+func (spec SnippetImport) keepsAlias(alias string) bool {
+	switch spec.Alias {
+	case "", "_":
+		return true
+	case ".":
+		return alias == ""
+	}
+	return spec.Alias == alias
+}
+
+// importAliaser resolves the package without holding the lock, since resolvers may shell out to the go tool
 func (file *File) importAliaser(importPath string) string {
+	if importPath == file.PkgPath() {
+		return ""
+	}
+
+	file.mu.Lock()
+	alias, ok := file.imports[importPath]
+	file.mu.Unlock()
+
+	if ok {
+		return alias
+	}
+
+	pkg, err := file.resolve(importPath)
+
+	file.mu.Lock()
+	defer file.mu.Unlock()
+
+	return file.alias(importPath, pkg, err)
+}
+
+// Prefetch resolves the import paths the file is going to use in one batch, since the file
+// itself asks the resolver for each new import path on its first use.
+func (file *File) Prefetch(importPaths ...string) error {
+	_, err := file.packageResolver().Resolve(importPaths...)
+	return err
+}
+
+func (file *File) packageResolver() PackageResolver {
+	if file.resolver == nil {
+		return DefaultPackageResolver
+	}
+	return file.resolver
+}
+
+func (file *File) resolve(importPath string) (*Package, error) {
+	pkgs, err := file.packageResolver().Resolve(importPath)
+	if err == nil && len(pkgs) == 0 {
+		err = fmt.Errorf("`%s` not found", importPath)
+	}
+	if err != nil {
+		return &Package{PkgPath: importPath, Name: formatx.GuessPkgName(importPath)}, err
+	}
+	return pkgs[0], nil
+}
+
+func (file *File) initImports() {
 	if file.imports == nil {
 		file.imports = map[string]string{}
+		file.pkgNames = map[string]string{}
+	}
+}
+
+func (file *File) alias(importPath string, pkg *Package, err error) string {
+	file.initImports()
+
+	if alias, ok := file.imports[importPath]; ok {
+		return alias
+	}
+	if err != nil {
+		file.errs = append(file.errs, err)
+	}
+	if pkg.PkgPath == file.PkgPath() {
+		return ""
 	}
-	if file.imports[importPath] == "" {
-		pkgs, err := packages.Load(nil, importPath)
-		if err != nil {
-			panic(err)
+	if _, ok := file.imports[pkg.PkgPath]; !ok {
+		file.pkgNames[pkg.PkgPath] = pkg.Name
+		file.imports[pkg.PkgPath] = file.uniqueAlias(pkg.PkgPath, pkg.Name)
+	}
+	return file.imports[pkg.PkgPath]
+}
+
+func (file *File) uniqueAlias(importPath string, name string) string {
+	used := map[string]bool{}
+	for _, alias := range file.imports {
+		used[alias] = true
+	}
+
+	if !used[name] {
+		return name
+	}
+
+	parts := strings.Split(deVendor(importPath), "/")
+	for i := len(parts) - 2; i >= 0; i-- {
+		if formatx.IsMajorVersion(parts[i+1]) {
+			continue
 		}
-		if len(pkgs) == 0 {
-			panic(fmt.Errorf("`%s` not found", importPath))
+		if alias := toIdent(parts[i]) + name; IsValidIdent(alias) && !used[alias] {
+			return alias
+		}
+		break
+	}
+
+	for i := 2; ; i++ {
+		if alias := name + strconv.Itoa(i); !used[alias] {
+			return alias
 		}
-		importPath = pkgs[0].PkgPath
-		file.imports[importPath] = LowerSnakeCase(importPath)
 	}
-	return file.imports[importPath]
+}
+func toIdent(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
 }
 
 func (file *File) Use(importPath string, exposedName string) string {
-	return file.importAliaser(importPath) + "." + exposedName
+	return qualified(file.importAliaser(importPath), exposedName)
+}
+
+func qualified(alias string, name string) string {
+	if alias == "" {
+		return name
+	}
+	return alias + "." + name
+}
+
+func pkgPathOfDir(dir string) (string, error) {
+	m, err := formatx.ModuleOf(dir)
+	if err != nil {
+		return "", err
+	}
+	return m.PkgPathOf(dir)
 }
 
 func deVendor(importPath string) string {
@@ -100,28 +455,53 @@ func deVendor(importPath string) string {
 }
 
 func (file *File) WriteFile() (int, error) {
-	dir := filepath.Dir(file.filename)
+	data, err := file.Render()
+	if err != nil {
+		return -1, err
+	}
 
-	if dir != "" {
-		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+	if file.typeCheck {
+		if err := typeCheckFiles(file); err != nil {
 			return -1, err
 		}
 	}
 
-	f, err := os.Create(file.filename)
-	defer f.Close()
-	if err != nil {
+	if _, err := writeFileIfChanged(file.outputFS(), file.filename, data); err != nil {
 		return -1, err
 	}
 
-	n3, err := f.Write(file.Bytes())
-	if err != nil {
-		return -1, err
+	return len(data), nil
+}
+
+func GeneratedHeader(generator string) string {
+	return "// Code generated by " + generator + ". DO NOT EDIT."
+}
+
+func IsGenerated(src []byte) bool {
+	f, err := parseHeader(src)
+	return err == nil && ast.IsGenerated(f)
+}
+
+func isGeneratedBy(src []byte, generator string) bool {
+	f, err := parseHeader(src)
+	if err != nil || !ast.IsGenerated(f) {
+		return false
 	}
 
-	if err := f.Sync(); err != nil {
-		return -1, err
+	for _, cg := range f.Comments {
+		if cg.Pos() >= f.Package {
+			break
+		}
+		for _, c := range cg.List {
+			if c.Text == GeneratedHeader(generator) {
+				return true
+			}
+		}
 	}
 
-	return n3, nil
+	return false
+}
+
+func parseHeader(src []byte) (*ast.File, error) {
+	return parser.ParseFile(token.NewFileSet(), "", src, parser.PackageClauseOnly|parser.ParseComments)
 }
@@ -0,0 +1,98 @@
+package codegen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/scanner"
+	"go/token"
+
+	"github.com/go-courier/codegen/formatx"
+)
+
+func newFileError(filename string, src []byte, err error) *FileError {
+	e := &FileError{
+		Filename: filename,
+		Err:      err,
+	}
+
+	formatErr := &formatx.Error{}
+	errList := scanner.ErrorList{}
+
+	switch {
+	case errors.As(err, &formatErr) && len(formatErr.Errors) > 0:
+		e.Line = formatErr.Errors[0].Pos.Line
+		e.Column = formatErr.Errors[0].Pos.Column
+		e.Source = formatErr.Errors[0].Source()
+	case errors.As(err, &errList) && len(errList) > 0:
+		e.Line = errList[0].Pos.Line
+		e.Column = errList[0].Pos.Column
+		e.Source = sourceWindow(src, e.Line, 3)
+	}
+
+	if e.Line > 0 {
+		if origin, ok := originOfLine(src, e.Line); ok {
+			e.Origin = origin
+		}
+	}
+
+	return e
+}
+
+type FileError struct {
+	Filename string
+	Line     int
+	Column   int
+	Source   string
+	Origin   token.Position
+	Err      error
+}
+
+func (e *FileError) Error() string {
+	buf := &bytes.Buffer{}
+
+	buf.WriteString(e.Filename)
+	if e.Line > 0 {
+		buf.WriteString(fmt.Sprintf(":%d:%d", e.Line, e.Column))
+	}
+	buf.WriteString(": ")
+	buf.WriteString(e.message())
+
+	if e.Origin.IsValid() {
+		buf.WriteString(" (from ")
+		buf.WriteString(e.Origin.String())
+		buf.WriteString(")")
+	}
+
+	if e.Source != "" {
+		buf.WriteRune('\n')
+		buf.WriteString(e.Source)
+	}
+
+	return buf.String()
+}
+
+// message leaves out the position of a format error, which is already printed in front of it
+func (e *FileError) message() string {
+	formatErr := &formatx.Error{}
+	if !errors.As(e.Err, &formatErr) || len(formatErr.Errors) == 0 {
+		return e.Err.Error()
+	}
+
+	msg := fmt.Sprintf("%s failed: %s", formatErr.Op, formatErr.Errors[0].Msg)
+	if n := len(formatErr.Errors) - 1; n > 0 {
+		msg += fmt.Sprintf(" (and %d more errors)", n)
+	}
+	return msg
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+func sourceWindow(src []byte, line int, around int) string {
+	return (&formatx.SourceError{
+		Pos:     token.Position{Line: line},
+		Context: formatx.SourceContext(src, line, around),
+	}).Source()
+}
@@ -1,7 +1,17 @@
 package codegen
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-courier/codegen/formatx"
+	"github.com/stretchr/testify/require"
 )
 
 func ExampleNewFile_hello() {
@@ -18,7 +28,7 @@ func ExampleNewFile_hello() {
 	//package main
 	//
 	//import (
-	//	fmt "fmt"
+	//	"fmt"
 	//)
 	//
 	//func main() {
@@ -52,7 +62,7 @@ func ExampleNewFile_main() {
 	//package main
 	//
 	//import (
-	//	fmt "fmt"
+	//	"fmt"
 	//)
 	//
 	//func fibonacci(n int, c chan int) {
@@ -72,3 +82,372 @@ func ExampleNewFile_main() {
 	//	}
 	//}
 }
+
+func ExampleNewFile_importAliases() {
+	file := NewFile("main", "examples/rand/rand.go")
+
+	file.WriteBlock(
+		Func().Named("main").Do(
+			Call(file.Use("fmt", "Println"), Call(file.Use("math/rand", "Int"))),
+			Call(file.Use("crypto/rand", "Read"), Call("make", Slice(Byte), Val(8))),
+		),
+	)
+
+	fmt.Println(string(file.Bytes()))
+	// Output:
+	//package main
+	//
+	//import (
+	//	cryptorand "crypto/rand"
+	//	"fmt"
+	//	"math/rand"
+	//)
+	//
+	//func main() {
+	//	fmt.Println(rand.Int())
+	//	cryptorand.Read(make([]byte, 8))
+	//}
+}
+
+func TestFile_WithResolver(t *testing.T) {
+	tt := require.New(t)
+
+	file := NewFile("main", "main.go").WithResolver(StaticPackageResolver{
+		"github.com/go-courier/x/v2": "xx",
+	})
+
+	tt.Equal("xx.Do", file.Use("github.com/go-courier/x/v2", "Do"))
+	tt.Equal("yaml.Marshal", file.Use("gopkg.in/yaml.v2", "Marshal"))
+	tt.Equal(`package main
+
+import (
+	"github.com/go-courier/x/v2"
+	"gopkg.in/yaml.v2"
+)
+`, string(file.Bytes()))
+}
+
+func TestFile_importAliaser(t *testing.T) {
+	tt := require.New(t)
+
+	file := NewFile("main", "main.go")
+
+	tt.Equal("template", file.importAliaser("text/template"))
+	tt.Equal("htmltemplate", file.importAliaser("html/template"))
+	tt.Equal("template", file.importAliaser("text/template"))
+
+	file.imports["github.com/x/template"] = "xtemplate"
+	file.imports["github.com/y/template"] = "template2"
+	tt.Equal("template3", file.uniqueAlias("github.com/x/template", "template"))
+	tt.Equal("ytemplate", file.uniqueAlias("github.com/y/y/v2", "template"))
+}
+
+type failedPackageResolver struct{}
+
+func (failedPackageResolver) Resolve(importPaths ...string) ([]*Package, error) {
+	return nil, errors.New("resolve failed")
+}
+
+func TestFile_Render(t *testing.T) {
+	t.Run("syntax error", func(t *testing.T) {
+		tt := require.New(t)
+
+		file := NewFile("main", "main.go")
+		file.WriteBlock(
+			Func().Named("main").Do(
+				Call(file.Use("fmt", "Println"), Val(1)),
+			),
+			Expr("func broken( {"),
+		)
+
+		_, err := file.Render()
+		tt.Error(err)
+
+		fileErr := &FileError{}
+		tt.True(errors.As(err, &fileErr))
+		tt.Equal("main.go", fileErr.Filename)
+		tt.Equal(9, fileErr.Line)
+		tt.True(strings.HasPrefix(err.Error(), "main.go:9:14: parse failed: expected ')', found '{'"), err.Error())
+		tt.Equal(1, strings.Count(err.Error(), "main.go:"))
+		tt.Contains(fileErr.Source, ">    9\tfunc broken( {")
+		tt.Contains(fileErr.Source, "    6\tfmt.Println(1)")
+
+		tt.Error(TryCatch(func() {
+			file.Bytes()
+		}))
+	})
+
+	t.Run("resolve error", func(t *testing.T) {
+		tt := require.New(t)
+
+		file := NewFile("main", filepath.Join(t.TempDir(), "main.go")).WithResolver(failedPackageResolver{})
+		file.WriteBlock(
+			Func().Named("main").Do(
+				Call(file.Use("github.com/x/y", "Println"), Val(1)),
+			),
+		)
+
+		_, err := file.WriteFile()
+		tt.EqualError(errors.Unwrap(err), "resolve failed")
+
+		_, statErr := os.Stat(file.filename)
+		tt.True(os.IsNotExist(statErr))
+	})
+}
+
+func TestFile_WriteFile(t *testing.T) {
+	tt := require.New(t)
+
+	filename := filepath.Join(t.TempDir(), "hello", "hello.go")
+
+	newFile := func() *File {
+		file := NewFile("hello", filename).WithGenerator("codegen")
+		file.WriteBlock(
+			Func().Named("Hello").Do(
+				Call(file.Use("fmt", "Println"), file.Val("Hello")),
+			),
+		)
+		return file
+	}
+
+	_, err := newFile().WriteFile()
+	tt.NoError(err)
+
+	data, err := os.ReadFile(filename)
+	tt.NoError(err)
+	tt.True(IsGenerated(data))
+	tt.False(IsGenerated([]byte("package hello\n\n// Code generated by codegen. DO NOT EDIT.\n")))
+	tt.False(IsGenerated([]byte("package hello\n\nvar s = `\n// Code generated by codegen. DO NOT EDIT.\n`\n")))
+	tt.Equal(`// Code generated by codegen. DO NOT EDIT.
+
+package hello
+
+import (
+	"fmt"
+)
+
+func Hello() {
+	fmt.Println("Hello")
+}
+`, string(data))
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	tt.NoError(os.Chtimes(filename, modTime, modTime))
+
+	_, err = newFile().WriteFile()
+	tt.NoError(err)
+
+	info, err := os.Stat(filename)
+	tt.NoError(err)
+	tt.Equal(modTime, info.ModTime())
+
+	entries, err := os.ReadDir(filepath.Dir(filename))
+	tt.NoError(err)
+	tt.Len(entries, 1)
+}
+
+func TestFile_WithBuildConstraint(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		tt := require.New(t)
+
+		file := NewFile("sys", "sys_linux.go").
+			WithGenerator("codegen").
+			WithBuildConstraint("linux").
+			WithBuildConstraint("amd64 || arm64")
+
+		file.WriteBlock(
+			Func().Named("Getpid").Return(Var(Int)).Do(
+				Return(Call(file.Use("syscall", "Getpid"))),
+			),
+		)
+
+		tt.Equal(`// Code generated by codegen. DO NOT EDIT.
+
+//go:build linux && (amd64 || arm64)
+
+package sys
+
+import (
+	"syscall"
+)
+
+func Getpid() int {
+	return syscall.Getpid()
+}
+`, string(file.Bytes()))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		tt := require.New(t)
+
+		file := NewFile("sys", "sys.go").WithBuildConstraint("linux &&")
+
+		_, err := file.Render()
+		tt.Error(err)
+		tt.Contains(err.Error(), "invalid build constraint `linux &&`")
+	})
+}
+
+func TestFile_WithHeaderAndPackageDoc(t *testing.T) {
+	tt := require.New(t)
+
+	file := NewFile("sys", "doc.go").
+		WithHeader("SPDX-License-Identifier: Apache-2.0", "Copyright 2021 The Authors\n\nLicensed under the Apache License.").
+		WithGenerator("codegen").
+		WithBuildConstraint("linux").
+		WithPackageDoc("Package sys provides system calls.")
+
+	file.WriteBlock(DeclConst(Assign(Id("Version")).By(Val("v1"))))
+
+	tt.Equal(`// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 The Authors
+//
+// Licensed under the Apache License.
+
+// Code generated by codegen. DO NOT EDIT.
+
+//go:build linux
+
+// Package sys provides system calls.
+package sys
+
+const Version = "v1"
+`, string(file.Bytes()))
+
+	_, f, err := formatx.ParseFile("doc.go", file.Bytes())
+	tt.NoError(err)
+	tt.Equal("Package sys provides system calls.\n", f.Doc.Text())
+}
+
+func TestFile_Import(t *testing.T) {
+	tt := require.New(t)
+
+	file := NewFile("main", "main.go").WithResolver(StaticPackageResolver{})
+
+	file.Import(
+		Import("embed").AsBlank(),
+		Import("github.com/lib/pq").AsBlank().WithComments("register postgres driver"),
+		Import("math").AsDot(),
+		Import("github.com/pkg/errors").As("pkgerrors").WithLineComment("wrapping"),
+		Import("strings"),
+	)
+
+	file.WriteBlock(
+		Func().Named("main").Do(
+			Call(file.Use("fmt", "Println"), CallWith(Id(file.Use("math", "Abs")), Val(-1))),
+			Call(file.Use("github.com/pkg/errors", "New"), Call(file.Use("strings", "TrimSpace"), Val(" "))),
+		),
+	)
+
+	tt.Equal(`package main
+
+import (
+	"fmt"
+	"strings"
+
+	pkgerrors "github.com/pkg/errors" // wrapping
+
+	_ "embed"
+	// register postgres driver
+	_ "github.com/lib/pq"
+	. "math"
+)
+
+func main() {
+	fmt.Println(Abs(-1))
+	pkgerrors.New(strings.TrimSpace(" "))
+}
+`, string(file.Bytes()))
+
+	file.Import(Import("github.com/x/errors").As("pkgerrors"))
+
+	_, err := file.Render()
+	tt.Error(err)
+	tt.Contains(err.Error(), "import alias `pkgerrors` of `github.com/x/errors` is already used by `github.com/pkg/errors`")
+}
+
+func TestFile_Import_ConflictsWithUsedAlias(t *testing.T) {
+	for _, spec := range []*SnippetImport{
+		Import("errors").As("stderrors"),
+		Import("errors").AsDot(),
+	} {
+		tt := require.New(t)
+
+		file := NewFile("main", "main.go").WithResolver(StaticPackageResolver{})
+		file.WriteBlock(Call(file.Use("errors", "New"), Val("")))
+		file.Import(spec)
+
+		_, err := file.Render()
+		tt.Error(err)
+		tt.Contains(err.Error(), "conflicts with its existing alias `errors`")
+	}
+}
+
+func TestFile_PkgPath(t *testing.T) {
+	t.Run("derived from go.mod", func(t *testing.T) {
+		tt := require.New(t)
+
+		tt.Equal("github.com/go-courier/codegen", NewFile("codegen", "codegen.go").PkgPath())
+		tt.Equal("github.com/go-courier/codegen/formatx", NewFile("formatx", "formatx/formatx.go").PkgPath())
+		tt.Equal("", NewFile("codegen_test", "codegen_test.go").PkgPath())
+		tt.Equal("", NewFile("codegen", filepath.Join(t.TempDir(), "codegen.go")).PkgPath())
+	})
+
+	t.Run("same package references", func(t *testing.T) {
+		tt := require.New(t)
+
+		file := NewFile("codegen", "types__generated.go")
+
+		file.WriteBlock(
+			DeclVar(
+				Assign(Var(file.TypeOf(reflect.TypeOf(&SnippetField{})), "field")).By(
+					Call(file.Use("github.com/go-courier/codegen", "Var"), Id(file.Use("github.com/go-courier/codegen", "Int"))),
+				),
+				Assign(Id("comments")).By(file.Val(SnippetComments{"a"})),
+				Assign(Id("fn")).By(Id(file.Use("github.com/go-courier/codegen/formatx", "Format"))),
+			),
+		)
+
+		tt.Equal(`package codegen
+
+import (
+	"github.com/go-courier/codegen/formatx"
+)
+
+var (
+	field    *SnippetField = Var(Int)
+	comments               = SnippetComments{
+		"a",
+	}
+	fn = formatx.Format
+)
+`, string(file.Bytes()))
+	})
+
+	t.Run("explicit", func(t *testing.T) {
+		tt := require.New(t)
+
+		file := NewFile("models", "models.go").WithPkgPath("github.com/x/models").WithResolver(StaticPackageResolver{})
+		tt.Equal("User", file.Use("github.com/x/models", "User"))
+		tt.Equal("time.Time", file.Use("time", "Time"))
+	})
+}
+
+func TestFile_WithProcesses(t *testing.T) {
+	tt := require.New(t)
+
+	file := NewFile("main", "main.go").WithResolver(StaticPackageResolver{}).WithProcesses(formatx.StrictProcesses...)
+
+	file.WriteBlock(
+		Func().Named("main").Do(
+			file.Expr("\n_ = 0755\n"),
+		),
+	)
+
+	tt.Equal(`package main
+
+func main() {
+	_ = 0o755
+}
+`, string(file.Bytes()))
+}
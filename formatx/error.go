@@ -0,0 +1,100 @@
+package formatx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/scanner"
+	"go/token"
+)
+
+const contextLines = 3
+
+func newError(filename string, src []byte, op string, err error) *Error {
+	e := &Error{
+		Filename: filename,
+		Op:       op,
+		Err:      err,
+	}
+
+	errList := scanner.ErrorList{}
+	if errors.As(err, &errList) {
+		for _, item := range errList {
+			e.Errors = append(e.Errors, &SourceError{
+				Pos:     item.Pos,
+				Msg:     item.Msg,
+				Context: SourceContext(src, item.Pos.Line, contextLines),
+			})
+		}
+	}
+
+	return e
+}
+
+type Error struct {
+	Filename string
+	Op       string
+	Errors   []*SourceError
+	Err      error
+}
+
+func (e *Error) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("%s: %s failed: %s", e.Filename, e.Op, e.Err)
+	}
+
+	msg := fmt.Sprintf("%s failed: %s", e.Op, e.Errors[0])
+	if n := len(e.Errors) - 1; n > 0 {
+		msg += fmt.Sprintf(" (and %d more errors)", n)
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+type SourceError struct {
+	Pos     token.Position
+	Msg     string
+	Context []SourceLine
+}
+
+func (e *SourceError) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+func (e *SourceError) Source() string {
+	buf := &bytes.Buffer{}
+
+	for _, l := range e.Context {
+		if l.Line == e.Pos.Line {
+			buf.WriteString(fmt.Sprintf("> %4d\t", l.Line))
+		} else {
+			buf.WriteString(fmt.Sprintf("  %4d\t", l.Line))
+		}
+		buf.WriteString(l.Text)
+		buf.WriteRune('\n')
+	}
+
+	return buf.String()
+}
+
+type SourceLine struct {
+	Line int
+	Text string
+}
+
+func SourceContext(src []byte, line int, around int) []SourceLine {
+	lines := bytes.Split(src, []byte("\n"))
+	context := make([]SourceLine, 0, 2*around+1)
+
+	for i := line - around; i <= line+around; i++ {
+		if i < 1 || i > len(lines) {
+			continue
+		}
+		context = append(context, SourceLine{Line: i, Text: string(lines[i-1])})
+	}
+
+	return context
+}
@@ -0,0 +1,491 @@
+package formatx
+
+import (
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+func FixImportsProcess(fset *token.FileSet, f *ast.File, filename string) error {
+	dir := filepath.Dir(filename)
+
+	declared := siblingDecls(dir, filename, f.Name.Name)
+	for _, decl := range f.Decls {
+		for _, name := range declNames(decl) {
+			declared[name] = true
+		}
+	}
+
+	refs := packageRefs(f, declared)
+
+	imported := map[string]bool{}
+
+	for _, spec := range append([]*ast.ImportSpec{}, f.Imports...) {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+
+		name := ""
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+
+		switch name {
+		case "_", ".":
+			continue
+		case "":
+			pkgName, ok := importedPackageName(dir, importPath)
+			if !ok {
+				// the real name is unknown offline, so never treat the import as unused
+				imported[GuessPkgName(importPath)] = true
+				imported[path.Base(importPath)] = true
+				continue
+			}
+			name = pkgName
+		}
+
+		if _, ok := refs[name]; ok {
+			imported[name] = true
+			continue
+		}
+
+		astutil.DeleteNamedImport(fset, f, importName(spec), importPath)
+	}
+
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		if !imported[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	selfPkgPath := ""
+	if m, err := ModuleOf(dir); err == nil {
+		selfPkgPath, _ = m.PkgPathOf(dir)
+	}
+
+	for _, name := range names {
+		if importPath, ok := findPackage(dir, selfPkgPath, name, refs[name]); ok {
+			if GuessPkgName(importPath) == name {
+				astutil.AddImport(fset, f, importPath)
+			} else {
+				astutil.AddNamedImport(fset, f, name, importPath)
+			}
+		}
+	}
+
+	return nil
+}
+
+func importName(spec *ast.ImportSpec) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	return ""
+}
+
+func packageRefs(f *ast.File, declared map[string]bool) map[string]map[string]bool {
+	refs := map[string]map[string]bool{}
+
+	ast.Inspect(f, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.ImportSpec:
+			return false
+		case *ast.SelectorExpr:
+			ident, ok := n.X.(*ast.Ident)
+			if !ok || ident.Obj != nil || declared[ident.Name] || ident.Name == "_" {
+				return true
+			}
+			if refs[ident.Name] == nil {
+				refs[ident.Name] = map[string]bool{}
+			}
+			refs[ident.Name][n.Sel.Name] = true
+			return false
+		}
+		return true
+	})
+
+	return refs
+}
+
+func siblingDecls(dir string, filename string, pkgName string) map[string]bool {
+	declared := map[string]bool{}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return declared
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || filepath.Base(filename) == name {
+			continue
+		}
+
+		f, err := parser.ParseFile(token.NewFileSet(), filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		if err != nil || f.Name.Name != pkgName {
+			continue
+		}
+
+		for _, decl := range f.Decls {
+			for _, n := range declNames(decl) {
+				declared[n] = true
+			}
+		}
+	}
+
+	return declared
+}
+
+func declNames(decl ast.Decl) []string {
+	names := make([]string, 0)
+
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Recv == nil {
+			names = append(names, d.Name.Name)
+		}
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				names = append(names, s.Name.Name)
+			case *ast.ValueSpec:
+				for _, n := range s.Names {
+					names = append(names, n.Name)
+				}
+			}
+		}
+	}
+
+	return names
+}
+
+func importedPackageName(dir string, importPath string) (string, bool) {
+	if pkgDir, ok := packageDir(dir, importPath); ok {
+		if pkg := loadPackageExports(pkgDir); pkg != nil {
+			return pkg.name, true
+		}
+	}
+	return "", false
+}
+
+func findPackage(dir string, selfPkgPath string, name string, selectors map[string]bool) (string, bool) {
+	tiers := [][]string{
+		stdCandidates(name),
+	}
+
+	if m, err := ModuleOf(dir); err == nil {
+		tiers = append(tiers, moduleIndexOf(m.Path, m.Dir)[name], dependencyCandidates(m, name))
+	}
+
+	for _, candidates := range tiers {
+		matched := make([]string, 0)
+
+		for _, importPath := range candidates {
+			if importPath == selfPkgPath {
+				continue
+			}
+
+			pkgDir, ok := packageDir(dir, importPath)
+			if !ok {
+				continue
+			}
+
+			pkg := loadPackageExports(pkgDir)
+			if pkg == nil || pkg.name != name || !pkg.exportsAll(selectors) {
+				continue
+			}
+
+			matched = append(matched, importPath)
+		}
+
+		if len(matched) > 0 {
+			sort.Slice(matched, func(i, j int) bool {
+				if len(matched[i]) != len(matched[j]) {
+					return len(matched[i]) < len(matched[j])
+				}
+				return matched[i] < matched[j]
+			})
+			return matched[0], true
+		}
+	}
+
+	return "", false
+}
+
+func stdCandidates(name string) []string {
+	candidates := make([]string, 0)
+
+	for _, importPath := range StdPackages() {
+		if isInternal(importPath) {
+			continue
+		}
+		if GuessPkgName(importPath) == name {
+			candidates = append(candidates, importPath)
+		}
+	}
+
+	return candidates
+}
+
+func dependencyCandidates(m *Module, name string) []string {
+	candidates := make([]string, 0)
+
+	for _, dep := range requiredModules(m.Dir) {
+		for _, importPath := range moduleIndexOf(dep.Path, dep.Dir)[name] {
+			if !isInternal(importPath) {
+				candidates = append(candidates, importPath)
+			}
+		}
+	}
+
+	return candidates
+}
+
+func packageDir(dir string, importPath string) (string, bool) {
+	if IsStdPackage(importPath) {
+		return existingDir(filepath.Join(build.Default.GOROOT, "src", filepath.FromSlash(importPath)))
+	}
+
+	m, err := ModuleOf(dir)
+	if err != nil {
+		return "", false
+	}
+
+	var found *Module
+
+	for _, mod := range append([]*Module{m}, requiredModules(m.Dir)...) {
+		if mod.Contains(importPath) && (found == nil || len(mod.Path) > len(found.Path)) {
+			found = mod
+		}
+	}
+
+	if found == nil {
+		return "", false
+	}
+
+	rel := strings.TrimPrefix(strings.TrimPrefix(importPath, found.Path), "/")
+	return existingDir(filepath.Join(found.Dir, filepath.FromSlash(rel)))
+}
+
+func existingDir(dir string) (string, bool) {
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return "", false
+	}
+	return dir, true
+}
+
+var requiredModulesCache = sync.Map{}
+
+func requiredModules(moduleDir string) []*Module {
+	if modules, ok := requiredModulesCache.Load(moduleDir); ok {
+		return modules.([]*Module)
+	}
+
+	modules := make([]*Module, 0)
+
+	filename := filepath.Join(moduleDir, "go.mod")
+	if data, err := os.ReadFile(filename); err == nil {
+		if mf, err := modfile.Parse(filename, data, nil); err == nil {
+			replaces := map[string]module.Version{}
+			for _, r := range mf.Replace {
+				replaces[r.Old.Path] = r.New
+			}
+
+			for _, r := range mf.Require {
+				dep := r.Mod
+
+				if replaced, ok := replaces[dep.Path]; ok {
+					if modfile.IsDirectoryPath(replaced.Path) {
+						dir := replaced.Path
+						if !filepath.IsAbs(dir) {
+							dir = filepath.Join(moduleDir, dir)
+						}
+						modules = append(modules, &Module{Path: dep.Path, Dir: dir})
+						continue
+					}
+					dep = replaced
+				}
+
+				if dir, ok := moduleCacheDir(dep); ok {
+					modules = append(modules, &Module{Path: r.Mod.Path, Dir: dir})
+				}
+			}
+		}
+	}
+
+	requiredModulesCache.Store(moduleDir, modules)
+	return modules
+}
+
+func moduleCacheDir(v module.Version) (string, bool) {
+	modCache := os.Getenv("GOMODCACHE")
+	if modCache == "" {
+		gopath := filepath.SplitList(build.Default.GOPATH)
+		if len(gopath) == 0 {
+			return "", false
+		}
+		modCache = filepath.Join(gopath[0], "pkg", "mod")
+	}
+
+	escapedPath, err := module.EscapePath(v.Path)
+	if err != nil {
+		return "", false
+	}
+	escapedVersion, err := module.EscapeVersion(v.Version)
+	if err != nil {
+		return "", false
+	}
+
+	return existingDir(filepath.Join(modCache, escapedPath+"@"+escapedVersion))
+}
+
+var moduleIndexCache = sync.Map{}
+
+func moduleIndexOf(modulePath string, moduleDir string) map[string][]string {
+	if index, ok := moduleIndexCache.Load(moduleDir); ok {
+		return index.(map[string][]string)
+	}
+
+	index := map[string][]string{}
+
+	_ = filepath.WalkDir(moduleDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+
+		if p != moduleDir {
+			name := d.Name()
+			if name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(p, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+		}
+
+		if name, ok := packageNameOfDir(p); ok {
+			rel, _ := filepath.Rel(moduleDir, p)
+			importPath := path.Join(modulePath, filepath.ToSlash(rel))
+			index[name] = append(index[name], importPath)
+		}
+
+		return nil
+	})
+
+	moduleIndexCache.Store(moduleDir, index)
+	return index
+}
+
+func packageNameOfDir(dir string) (string, bool) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", false
+	}
+
+	for _, entry := range entries {
+		if !isPackageFile(dir, entry) {
+			continue
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), filepath.Join(dir, entry.Name()), nil, parser.PackageClauseOnly)
+		if err != nil || f.Name.Name == "main" || f.Name.Name == "documentation" {
+			continue
+		}
+		return f.Name.Name, true
+	}
+
+	return "", false
+}
+
+func isPackageFile(dir string, entry fs.DirEntry) bool {
+	name := entry.Name()
+	if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+		return false
+	}
+	ok, err := build.Default.MatchFile(dir, name)
+	return err == nil && ok
+}
+
+type packageExports struct {
+	name    string
+	exports map[string]bool
+}
+
+func (pkg *packageExports) exportsAll(selectors map[string]bool) bool {
+	for sel := range selectors {
+		if !pkg.exports[sel] {
+			return false
+		}
+	}
+	return true
+}
+
+var packageExportsCache = sync.Map{}
+
+func loadPackageExports(dir string) *packageExports {
+	if pkg, ok := packageExportsCache.Load(dir); ok {
+		return pkg.(*packageExports)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var pkg *packageExports
+
+	for _, entry := range entries {
+		if !isPackageFile(dir, entry) {
+			continue
+		}
+
+		f, err := parser.ParseFile(token.NewFileSet(), filepath.Join(dir, entry.Name()), nil, parser.SkipObjectResolution)
+		if err != nil || f.Name.Name == "main" || f.Name.Name == "documentation" {
+			continue
+		}
+
+		if pkg == nil {
+			pkg = &packageExports{name: f.Name.Name, exports: map[string]bool{}}
+		}
+		if f.Name.Name != pkg.name {
+			continue
+		}
+
+		for _, decl := range f.Decls {
+			for _, name := range declNames(decl) {
+				if ast.IsExported(name) {
+					pkg.exports[name] = true
+				}
+			}
+		}
+	}
+
+	if pkg != nil {
+		packageExportsCache.Store(dir, pkg)
+	}
+
+	return pkg
+}
+
+func isInternal(importPath string) bool {
+	for _, part := range strings.Split(importPath, "/") {
+		if part == "internal" || part == "vendor" {
+			return true
+		}
+	}
+	return false
+}
@@ -2,7 +2,6 @@ package formatx
 
 import (
 	"bytes"
-	"fmt"
 	"go/ast"
 	"go/format"
 	"go/parser"
@@ -35,8 +34,7 @@ func Format(filename string, src []byte, processes ...Process) ([]byte, error) {
 
 	buf := bytes.NewBuffer(nil)
 	if err := format.Node(buf, fset, f); err != nil {
-		printCodesWithLineNumber(src)
-		return nil, fmt.Errorf("go codes format failed: %s in %s", err.Error(), filename)
+		return nil, newError(filename, src, "format", err)
 	}
 	return buf.Bytes(), nil
 }
@@ -45,14 +43,7 @@ func ParseFile(filename string, src []byte) (*token.FileSet, *ast.File, error) {
 	fileSet := token.NewFileSet()
 	file, err := parser.ParseFile(fileSet, filename, src, parser.ParseComments)
 	if err != nil {
-		printCodesWithLineNumber(src)
-		return nil, nil, fmt.Errorf("go codes parse failed: %s in %s", err.Error(), filename)
+		return nil, nil, newError(filename, src, "parse", err)
 	}
 	return fileSet, file, nil
 }
-
-func printCodesWithLineNumber(src []byte) {
-	for i, line := range bytes.Split(src, []byte("\n")) {
-		fmt.Printf("\t%d\t%s\n", i+1, line)
-	}
-}
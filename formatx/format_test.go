@@ -1,9 +1,13 @@
 package formatx
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path"
+	"path/filepath"
+	"sort"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -54,3 +58,411 @@ func Test(t *testing.T) {
 }
 `, string(result))
 }
+
+func TestSortImportsProcess_BuildConstraints(t *testing.T) {
+	result, err := Format("sys_linux.go", []byte(`// Code generated by codegen. DO NOT EDIT.
+
+//go:build linux && amd64
+
+package sys
+
+import (
+	"syscall"
+	"fmt"
+)
+
+func Getpid() {
+	fmt.Println(syscall.Getpid())
+}
+`), SortImportsProcess)
+
+	require.NoError(t, err)
+	require.Equal(t, `// Code generated by codegen. DO NOT EDIT.
+
+//go:build linux && amd64
+
+package sys
+
+import (
+	"fmt"
+	"syscall"
+)
+
+func Getpid() {
+	fmt.Println(syscall.Getpid())
+}
+`, string(result))
+}
+
+func TestSortImportsProcess_ImportComments(t *testing.T) {
+	result, err := Format("main.go", []byte(`package main
+
+import (
+	_ "embed"
+	"fmt"
+	// register postgres driver
+	_ "github.com/lib/pq"
+	pkgerrors "github.com/pkg/errors" // wrapping
+)
+`), SortImportsProcess)
+
+	require.NoError(t, err)
+	require.Equal(t, `package main
+
+import (
+	"fmt"
+
+	pkgerrors "github.com/pkg/errors" // wrapping
+
+	_ "embed"
+	// register postgres driver
+	_ "github.com/lib/pq"
+)
+`, string(result))
+}
+
+func TestSortImportsWithLocalPrefixes(t *testing.T) {
+	tt := require.New(t)
+
+	dir := t.TempDir()
+	writeFile := func(name string, content string) {
+		tt.NoError(os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), os.ModePerm))
+		tt.NoError(os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	writeFile("go.work", "go 1.22\n\nuse (\n\t./app\n\t./lib\n)\n")
+	writeFile("app/go.mod", "module example.com/app\n\ngo 1.22\n")
+	writeFile("lib/go.mod", "module example.com/lib\n\ngo 1.22\n")
+
+	result, err := Format(filepath.Join(dir, "app/cmd/main.go"), []byte(`package main
+
+import (
+	"example.com/app/internal"
+	"example.com/application"
+	"example.com/lib"
+	"fmt"
+	"github.com/acme/a"
+	"github.com/acme/b"
+	"github.com/org/x"
+	. "github.com/org/dot"
+)
+`), SortImportsWithLocalPrefixes("github.com/acme"))
+
+	tt.NoError(err)
+	tt.Equal(`package main
+
+import (
+	"fmt"
+
+	"example.com/application"
+	"github.com/org/x"
+
+	"github.com/acme/a"
+	"github.com/acme/b"
+
+	"example.com/app/internal"
+	"example.com/lib"
+
+	. "github.com/org/dot"
+)
+`, string(result))
+}
+
+func TestModuleOf(t *testing.T) {
+	tt := require.New(t)
+
+	cwd, _ := os.Getwd()
+
+	m, err := ModuleOf(cwd)
+	tt.NoError(err)
+	tt.Equal("github.com/go-courier/codegen", m.Path)
+	tt.True(m.Contains("github.com/go-courier/codegen/formatx"))
+	tt.False(m.Contains("github.com/go-courier/codegenx"))
+
+	pkgPath, err := m.PkgPathOf(cwd)
+	tt.NoError(err)
+	tt.Equal("github.com/go-courier/codegen/formatx", pkgPath)
+
+	_, err = ModuleOf(t.TempDir())
+	tt.Error(err)
+}
+
+func TestIsStdPackage(t *testing.T) {
+	tt := require.New(t)
+
+	tt.True(IsStdPackage("fmt"))
+	tt.True(IsStdPackage("net/http"))
+	tt.True(IsStdPackage("internal/abi"))
+
+	tt.False(IsStdPackage("net/http/testdata"))
+	tt.False(IsStdPackage("cmd/go"))
+	tt.False(IsStdPackage("vendor/golang.org/x/net/idna"))
+	tt.False(IsStdPackage("github.com/go-courier/codegen"))
+	tt.False(IsStdPackage("example"))
+	tt.False(IsStdPackage(""))
+
+	pkgs := StdPackages()
+	tt.Contains(pkgs, "fmt")
+	tt.True(sort.StringsAreSorted(pkgs))
+}
+
+func TestGuessPkgName(t *testing.T) {
+	tt := require.New(t)
+
+	tt.Equal("spew", GuessPkgName("github.com/davecgh/go-spew/spew"))
+	tt.Equal("yaml", GuessPkgName("gopkg.in/yaml.v2"))
+	tt.Equal("codegen", GuessPkgName("github.com/go-courier/codegen/v2"))
+	tt.Equal("errors", GuessPkgName("github.com/x/vendor/github.com/pkg/errors"))
+	tt.Equal("mylib", GuessPkgName("github.com/x/my-lib"))
+}
+
+func TestIsNewerGoVersion(t *testing.T) {
+	tt := require.New(t)
+
+	embeddedStdPackages()
+	tt.NotEmpty(stdPackagesVersion)
+	tt.NotContains(embeddedStdPackages(), "#")
+
+	tt.False(isNewerGoVersion("go1.22.1", "go1.23.0"))
+	tt.False(isNewerGoVersion("go1.23.0", "go1.23.0"))
+	tt.True(isNewerGoVersion("go1.24rc1", "go1.23.0"))
+	tt.True(isNewerGoVersion("devel go1.25-abcdef", "go1.23.0"))
+}
+
+func TestFixImportsProcess(t *testing.T) {
+	tt := require.New(t)
+
+	cwd, _ := os.Getwd()
+
+	result, err := Format(filepath.Join(cwd, "fix_imports_generated_test.go"), []byte(`package formatx
+
+import (
+	"fmt"
+	"os"
+	_ "embed"
+	unused "net/http"
+	r "math/rand"
+)
+
+func TestFix(t *testing.T) {
+	var strings = []string{}
+	_ = strings.Join
+
+	require.Equal(t, 0, r.Intn(1))
+	fmt.Println(sort.StringsAreSorted(strings), utf8.RuneLen('a'), cryptorand.Reader)
+	_ = ModuleOf
+	_ = codegen.Id
+}
+`), FixImportsProcess, SortImportsProcess)
+
+	tt.NoError(err)
+	tt.Equal(`package formatx
+
+import (
+	"fmt"
+	r "math/rand"
+	"sort"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-courier/codegen"
+
+	_ "embed"
+)
+
+func TestFix(t *testing.T) {
+	var strings = []string{}
+	_ = strings.Join
+
+	require.Equal(t, 0, r.Intn(1))
+	fmt.Println(sort.StringsAreSorted(strings), utf8.RuneLen('a'), cryptorand.Reader)
+	_ = ModuleOf
+	_ = codegen.Id
+}
+`, string(result))
+}
+
+func TestFixImportsProcess_UnknownPackages(t *testing.T) {
+	tt := require.New(t)
+
+	cwd, _ := os.Getwd()
+
+	src := `package formatx
+
+import (
+	"k8s.io/api/core/v1"
+
+	"github.com/unknown/my-lib"
+	pkgerrors "github.com/unknown/errors"
+)
+
+var _ = v1.Pod{}
+`
+
+	result, err := Format(filepath.Join(cwd, "fix_imports_generated_test.go"), []byte(src), FixImportsProcess)
+	tt.NoError(err)
+	tt.Equal(`package formatx
+
+import (
+	"k8s.io/api/core/v1"
+
+	"github.com/unknown/my-lib"
+)
+
+var _ = v1.Pod{}
+`, string(result))
+}
+
+func TestFormat_Error(t *testing.T) {
+	tt := require.New(t)
+
+	_, err := Format("broken.go", []byte(`package broken
+
+func a() {
+	x := 
+}
+
+func b( {
+}
+`))
+
+	formatErr := &Error{}
+	tt.True(errors.As(err, &formatErr))
+	tt.Equal("broken.go", formatErr.Filename)
+	tt.Equal("parse", formatErr.Op)
+	tt.Len(formatErr.Errors, 2)
+
+	tt.Equal(5, formatErr.Errors[0].Pos.Line)
+	tt.Equal([]SourceLine{
+		{Line: 2, Text: ""},
+		{Line: 3, Text: "func a() {"},
+		{Line: 4, Text: "\tx := "},
+		{Line: 5, Text: "}"},
+		{Line: 6, Text: ""},
+		{Line: 7, Text: "func b( {"},
+		{Line: 8, Text: "}"},
+	}, formatErr.Errors[0].Context)
+	tt.Contains(formatErr.Errors[0].Source(), ">    5\t}\n")
+
+	tt.Equal("parse failed: broken.go:5:1: expected operand, found '}' (and 1 more errors)", err.Error())
+}
+
+func bigFile(imports int, funcs int) []byte {
+	buf := bytes.NewBufferString("package big\n\nimport (\n")
+
+	for i := 0; i < imports; i++ {
+		switch i % 3 {
+		case 0:
+			fmt.Fprintf(buf, "\t\"github.com/go-courier/codegen/pkg%d\"\n", i)
+		case 1:
+			fmt.Fprintf(buf, "\t// pkg%d\n\tpkg%d \"github.com/vendor/pkg%d\" // vendor\n", i, i, i)
+		default:
+			fmt.Fprintf(buf, "\t_ \"github.com/blank/pkg%d\"\n", i)
+		}
+	}
+	buf.WriteString("\t\"fmt\"\n\t\"strings\"\n)\n")
+
+	for i := 0; i < funcs; i++ {
+		fmt.Fprintf(buf, "\n// Func%d does things\nfunc Func%d(s string) string {\n\treturn fmt.Sprint(strings.TrimSpace(s), %d)\n}\n", i, i, i)
+	}
+
+	return buf.Bytes()
+}
+
+func BenchmarkFormat(b *testing.B) {
+	for _, n := range []int{100, 10000} {
+		src := bigFile(n/10, n)
+
+		b.Run(fmt.Sprintf("%d funcs", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, _ = Format("big.go", src)
+			}
+		})
+
+		b.Run(fmt.Sprintf("%d funcs with SortImportsProcess", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, _ = Format("big.go", src, SortImportsProcess)
+			}
+		})
+	}
+}
+
+func TestSortImportsProcess_KeepsComments(t *testing.T) {
+	result, err := Format("main.go", []byte(`package main
+
+// imports
+import "github.com/pkg/errors" // errors
+
+import (
+	"os"
+
+	// floating
+
+	"fmt"
+	// trailing
+)
+`), SortImportsProcess)
+
+	require.NoError(t, err)
+	require.Equal(t, `package main
+
+// imports
+import (
+	"github.com/pkg/errors" // errors
+)
+
+import (
+	// floating
+	"fmt"
+	"os"
+	// trailing
+)
+`, string(result))
+}
+
+func TestSortImportsProcess_Reprint(t *testing.T) {
+	tt := require.New(t)
+
+	// the import ends the file, so there is no room to lay it out in place
+	result, err := Format("main.go", []byte("package main\n\nimport \"fmt\""), SortImportsProcess)
+
+	tt.NoError(err)
+	tt.Equal(`package main
+
+import (
+	"fmt"
+)
+`, string(result))
+}
+
+func TestSortImportsProcess_MultiLineComment(t *testing.T) {
+	tt := require.New(t)
+
+	result, err := Format("main.go", []byte(`package main
+
+import (
+	"github.com/go-courier/x"
+	"os" /* block
+	multi */
+)
+
+var _ = os.Args
+var _ = x.X
+`), SortImportsProcess)
+
+	tt.NoError(err)
+	tt.Equal(`package main
+
+import (
+	"os" /* block
+	multi */
+
+	"github.com/go-courier/x"
+)
+
+var _ = os.Args
+var _ = x.X
+`, string(result))
+}
@@ -0,0 +1,141 @@
+package formatx
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/mod/modfile"
+)
+
+type Module struct {
+	Path      string
+	Dir       string
+	GoVersion string
+}
+
+func (m *Module) Contains(importPath string) bool {
+	return hasPathPrefix(importPath, m.Path)
+}
+
+func (m *Module) PkgPathOf(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(m.Dir, dir)
+	if err != nil {
+		return "", err
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside of module %s", dir, m.Path)
+	}
+	return path.Join(m.Path, filepath.ToSlash(rel)), nil
+}
+
+var moduleCache = sync.Map{}
+
+func ModuleOf(dir string) (*Module, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if m, ok := moduleCache.Load(dir); ok {
+		return m.(*Module), nil
+	}
+
+	for d := dir; ; d = filepath.Dir(d) {
+		filename := filepath.Join(d, "go.mod")
+
+		data, err := os.ReadFile(filename)
+		if err == nil {
+			modulePath := modfile.ModulePath(data)
+			if modulePath == "" {
+				return nil, fmt.Errorf("missing module path in %s", filename)
+			}
+			m := &Module{Path: modulePath, Dir: d}
+			if mf, err := modfile.ParseLax(filename, data, nil); err == nil && mf.Go != nil {
+				m.GoVersion = mf.Go.Version
+			}
+			moduleCache.Store(dir, m)
+			return m, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		if filepath.Dir(d) == d {
+			return nil, fmt.Errorf("go.mod not found for %s", dir)
+		}
+	}
+}
+
+var workspaceCache = sync.Map{}
+
+func WorkspaceModules(dir string) ([]*Module, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if modules, ok := workspaceCache.Load(dir); ok {
+		return modules.([]*Module), nil
+	}
+
+	modules, err := workspaceModules(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	workspaceCache.Store(dir, modules)
+	return modules, nil
+}
+
+func workspaceModules(dir string) ([]*Module, error) {
+	m, err := ModuleOf(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	modules := []*Module{m}
+
+	for d := dir; ; d = filepath.Dir(d) {
+		filename := filepath.Join(d, "go.work")
+
+		data, err := os.ReadFile(filename)
+		if err == nil {
+			work, err := modfile.ParseWork(filename, data, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, use := range work.Use {
+				moduleDir := use.Path
+				if !filepath.IsAbs(moduleDir) {
+					moduleDir = filepath.Join(d, moduleDir)
+				}
+				if filepath.Clean(moduleDir) == m.Dir {
+					continue
+				}
+				if used, err := ModuleOf(moduleDir); err == nil {
+					modules = append(modules, used)
+				}
+			}
+			return modules, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		if filepath.Dir(d) == d {
+			return modules, nil
+		}
+	}
+}
+
+func hasPathPrefix(importPath string, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return prefix != "" && (importPath == prefix || strings.HasPrefix(importPath, prefix+"/"))
+}
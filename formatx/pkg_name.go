@@ -0,0 +1,40 @@
+package formatx
+
+import (
+	"strings"
+	"unicode"
+)
+
+func GuessPkgName(importPath string) string {
+	parts := strings.Split(importPath, "/vendor/")
+	parts = strings.Split(parts[len(parts)-1], "/")
+
+	name := parts[len(parts)-1]
+	if len(parts) > 1 && IsMajorVersion(name) {
+		name = parts[len(parts)-2]
+	}
+
+	name = strings.TrimPrefix(strings.TrimSuffix(name, "-go"), "go-")
+	if i := strings.Index(name, "."); i > 0 {
+		name = name[0:i]
+	}
+
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+func IsMajorVersion(s string) bool {
+	if len(s) < 2 || s[0] != 'v' {
+		return false
+	}
+	for _, r := range s[1:] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -2,140 +2,408 @@ package formatx
 
 import (
 	"bytes"
+	"fmt"
 	"go/ast"
 	"go/format"
+	"go/parser"
 	"go/token"
-	"io/ioutil"
-	"path"
 	"path/filepath"
-	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 )
 
 func SortImportsProcess(fset *token.FileSet, f *ast.File, filename string) error {
+	return sortImports(fset, f, filename, nil)
+}
+
+func SortImportsWithLocalPrefixes(prefixes ...string) Process {
+	return func(fset *token.FileSet, f *ast.File, filename string) error {
+		return sortImports(fset, f, filename, prefixes)
+	}
+}
+
+func sortImports(fset *token.FileSet, f *ast.File, filename string, prefixes []string) error {
 	ast.SortImports(fset, f)
-	dir := path.Dir(filename)
 
-	for _, decl := range f.Decls {
-		d, ok := decl.(*ast.GenDecl)
+	modules, _ := WorkspaceModules(filepath.Dir(filename))
+
+	for i := 0; i < len(f.Decls); i++ {
+		d, ok := f.Decls[i].(*ast.GenDecl)
 		if !ok || d.Tok != token.IMPORT || len(d.Specs) == 0 {
 			break
 		}
 
-		g := &groupSet{}
+		g := newGroupSet(modules, prefixes)
 
 		for i := range d.Specs {
-			g.register(d.Specs[i].(*ast.ImportSpec), dir)
+			g.register(d.Specs[i].(*ast.ImportSpec))
 		}
 
-		fileSet, file, err := ParseFile(filename, bytes.Replace(formatNode(fset, f), formatNode(fset, d), g.Bytes(), 1))
+		regrouped, err := g.regroup(fset, f, d)
 		if err != nil {
 			return err
 		}
-		*fset = *fileSet
-		*f = *file
+		if !regrouped {
+			if err := g.reprint(fset, f, i, filename); err != nil {
+				return err
+			}
+		}
 	}
+
+	sort.SliceStable(f.Comments, func(i, j int) bool {
+		return f.Comments[i].Pos() < f.Comments[j].Pos()
+	})
+
 	return nil
 }
 
-func formatNode(fileSet *token.FileSet, node ast.Node) []byte {
-	buf := &bytes.Buffer{}
-	if err := format.Node(buf, fileSet, node); err != nil {
-		panic(err)
+func newGroupSet(modules []*Module, prefixes []string) *groupSet {
+	return &groupSet{
+		modules:  modules,
+		prefixes: prefixes,
+		groups:   make([][]*ast.ImportSpec, len(prefixes)+4),
 	}
-	return buf.Bytes()
 }
 
-type groupSet [4][]*dep
+// groups are ordered as std, third party, each of local prefixes, modules of workspace, blank and dot imports
+type groupSet struct {
+	modules  []*Module
+	prefixes []string
+	groups   [][]*ast.ImportSpec
+}
 
-type dep struct {
-	name       string
-	pkgPath    string
-	importSpec *ast.ImportSpec
+// ImportGroup returns the index of the group SortImportsProcess puts the import of importPath named name in
+func ImportGroup(filename string, name string, importPath string) int {
+	modules, _ := WorkspaceModules(filepath.Dir(filename))
+	return newGroupSet(modules, nil).indexOf(name, importPath)
 }
 
-func (group *groupSet) Bytes() []byte {
-	buf := bytes.NewBuffer(nil)
+func (group *groupSet) register(importSpec *ast.ImportSpec) {
+	importPath, _ := strconv.Unquote(importSpec.Path.Value)
 
-	buf.WriteString("import (")
+	name := ""
+	if importSpec.Name != nil {
+		name = importSpec.Name.Name
+	}
 
-	for _, deps := range group {
-		for _, d := range deps {
-			buf.WriteRune('\n')
+	i := group.indexOf(name, importPath)
+	group.groups[i] = append(group.groups[i], importSpec)
+}
 
-			importSpec := d.importSpec
-			if importSpec.Doc != nil {
-				for _, c := range importSpec.Doc.List {
-					buf.WriteString(c.Text)
-					buf.WriteRune('\n')
+func (group *groupSet) indexOf(name string, importPath string) int {
+	// blank & dot
+	if name == "_" || name == "." {
+		return len(group.groups) - 1
+	}
+
+	// std
+	if IsStdPackage(importPath) {
+		return 0
+	}
+
+	// local prefixes
+	for i, prefix := range group.prefixes {
+		if hasPathPrefix(importPath, prefix) {
+			return 2 + i
+		}
+	}
+
+	// local modules
+	for _, m := range group.modules {
+		if m.Contains(importPath) {
+			return len(group.groups) - 2
+		}
+	}
+
+	// third party
+	return 1
+}
+
+// regroup reorders the specs of d by group and lays them out on fresh lines inside the
+// byte range of the declaration, so a blank line separates each group when printing.
+// It reports false without touching the AST when the new layout does not fit.
+func (group *groupSet) regroup(fset *token.FileSet, f *ast.File, d *ast.GenDecl) (bool, error) {
+	tf := fset.File(d.Pos())
+	if tf == nil {
+		return false, nil
+	}
+
+	lo := tf.Offset(d.Specs[0].Pos()) - 1
+	hi := tf.Offset(d.End())
+	if d.Lparen.IsValid() {
+		lo, hi = tf.Offset(d.Lparen), tf.Offset(d.Rparen)
+	}
+	if hi >= tf.Size() {
+		return false, nil
+	}
+
+	// each comment gets a single offset in the new layout, which cannot hold one spanning several lines
+	for _, cg := range f.Comments {
+		if offset := tf.Offset(cg.Pos()); offset > lo && offset < hi {
+			for _, c := range cg.List {
+				if strings.Contains(c.Text, "\n") {
+					return false, nil
+				}
+			}
+		}
+	}
+
+	leading, trailing := floatingComments(f, d, func(pos token.Pos) bool {
+		offset := tf.Offset(pos)
+		return offset > lo && offset < hi
+	})
+
+	group.sort()
+
+	layout := func(apply bool) (int, []int) {
+		cursor := lo + 1
+		lines := make([]int, 0)
+
+		newLine := func() {
+			lines = append(lines, cursor)
+		}
+		next := func() token.Pos {
+			pos := tf.Pos(cursor)
+			cursor++
+			return pos
+		}
+		comments := func(groups ...*ast.CommentGroup) {
+			for _, cg := range groups {
+				if cg == nil {
+					continue
+				}
+				for _, c := range cg.List {
+					newLine()
+					if pos := next(); apply {
+						c.Slash = pos
+					}
 				}
 			}
-			if importSpec.Name != nil {
-				buf.WriteString(importSpec.Name.String())
-				buf.WriteRune(' ')
+		}
+
+		first := true
+
+		for _, deps := range group.groups {
+			if len(deps) == 0 {
+				continue
 			}
-			buf.WriteString(importSpec.Path.Value)
-			if importSpec.Comment != nil {
-				for _, c := range importSpec.Comment.List {
-					buf.WriteString(c.Text)
+			if !first {
+				newLine()
+				cursor++
+			}
+			first = false
+
+			for _, spec := range deps {
+				comments(leading[spec]...)
+				comments(spec.Doc)
+
+				newLine()
+				if spec.Name != nil {
+					if pos := next(); apply {
+						spec.Name.NamePos = pos
+					}
+				}
+				if pos := next(); apply {
+					spec.Path.ValuePos = pos
+				}
+				if pos := next(); apply {
+					spec.EndPos = pos
+				}
+				if spec.Comment != nil {
+					for _, c := range spec.Comment.List {
+						if pos := next(); apply {
+							c.Slash = pos
+						}
+					}
 				}
 			}
 		}
-		buf.WriteRune('\n')
+
+		comments(trailing...)
+
+		return cursor, lines
+	}
+
+	cursor, lines := layout(false)
+	if cursor > hi {
+		return false, nil
 	}
 
-	buf.WriteRune(')')
-	return buf.Bytes()
+	newLines := make([]int, 0, tf.LineCount()+len(lines)+1)
+	for _, offset := range tf.Lines() {
+		if offset <= lo {
+			newLines = append(newLines, offset)
+		}
+	}
+	newLines = append(newLines, lines...)
+	newLines = append(newLines, hi)
+	for _, offset := range tf.Lines() {
+		if offset > hi {
+			newLines = append(newLines, offset)
+		}
+	}
+
+	for i := 1; i < len(newLines); i++ {
+		if newLines[i] <= newLines[i-1] {
+			return false, nil
+		}
+	}
+
+	layout(true)
+
+	if !d.Lparen.IsValid() {
+		d.Lparen = tf.Pos(lo)
+	}
+	d.Rparen = tf.Pos(hi)
+	d.Specs = group.specs()
+
+	if !tf.SetLines(newLines) {
+		return false, fmt.Errorf("%s: regroup imports: invalid line table", tf.Name())
+	}
+
+	return true, nil
 }
 
-type stdLibSet map[string]bool
+// reprint is the fallback of regroup: it prints the file with the regrouped import block
+// of the i-th declaration and parses the result back into f.
+func (group *groupSet) reprint(fset *token.FileSet, f *ast.File, i int, filename string) error {
+	buf := &bytes.Buffer{}
+	if err := format.Node(buf, fset, f); err != nil {
+		return err
+	}
+
+	printedFset, printed, err := ParseFile(filename, buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	d := printed.Decls[i].(*ast.GenDecl)
+	tf := printedFset.File(d.Pos())
+
+	byPath := map[string]*ast.ImportSpec{}
+	for _, spec := range d.Specs {
+		importSpec := spec.(*ast.ImportSpec)
+		byPath[importName(importSpec)+" "+importSpec.Path.Value] = importSpec
+	}
+
+	leading, trailing := floatingComments(printed, d, func(pos token.Pos) bool {
+		return pos > d.Pos() && pos < d.End()
+	})
 
-func (s stdLibSet) read(dir string, prefix string) {
-	files, _ := ioutil.ReadDir(dir)
+	group.sort()
 
-	for _, f := range files {
-		if f.IsDir() {
-			importPath := f.Name()
-			if prefix != "" {
-				importPath = filepath.Join(prefix, f.Name())
+	block := bytes.NewBufferString("import (")
+
+	writeComments := func(groups ...*ast.CommentGroup) {
+		for _, cg := range groups {
+			for _, c := range cg.List {
+				block.WriteString("\n")
+				block.WriteString(c.Text)
 			}
-			s.read(filepath.Join(dir, f.Name()), importPath)
-			s[importPath] = true
+		}
+	}
+
+	for _, deps := range group.groups {
+		if len(deps) == 0 {
 			continue
 		}
+		for _, dep := range deps {
+			spec := byPath[importName(dep)+" "+dep.Path.Value]
+
+			writeComments(leading[spec]...)
+			if spec.Doc != nil {
+				writeComments(spec.Doc)
+			}
+
+			block.WriteString("\n")
+			if spec.Name != nil {
+				block.WriteString(spec.Name.Name + " ")
+			}
+			block.WriteString(spec.Path.Value)
+			if spec.Comment != nil {
+				for _, c := range spec.Comment.List {
+					block.WriteString(" " + c.Text)
+				}
+			}
+		}
+		block.WriteString("\n")
+	}
+
+	writeComments(trailing...)
+	block.WriteString("\n)")
+
+	result := applyByteEdit(buf.Bytes(), tf.Offset(d.Pos()), tf.Offset(d.End()), block.Bytes())
+
+	file, err := parser.ParseFile(fset, filename, result, parser.ParseComments)
+	if err != nil {
+		return newError(filename, result, "parse", err)
 	}
+	*f = *file
+
+	return nil
 }
 
-var stdLibs = func() map[string]bool {
-	set := stdLibSet{}
-	set.read(runtime.GOROOT()+"/src", "")
-	return set
-}()
+func applyByteEdit(src []byte, start int, end int, text []byte) []byte {
+	result := make([]byte, 0, len(src)-(end-start)+len(text))
+	result = append(result, src[0:start]...)
+	result = append(result, text...)
+	return append(result, src[end:]...)
+}
 
-func (group *groupSet) register(importSpec *ast.ImportSpec, dir string) {
-	importPath, _ := strconv.Unquote(importSpec.Path.Value)
+// floatingComments collects the comments inside d which belong to no spec,
+// attaching each one to the following spec or to the end of the block.
+func floatingComments(f *ast.File, d *ast.GenDecl, inside func(pos token.Pos) bool) (map[*ast.ImportSpec][]*ast.CommentGroup, []*ast.CommentGroup) {
+	leading := map[*ast.ImportSpec][]*ast.CommentGroup{}
+	trailing := make([]*ast.CommentGroup, 0)
 
-	appendTo := func(i int) {
-		group[i] = append(group[i], &dep{
-			pkgPath:    importPath,
-			importSpec: importSpec,
-		})
+	attached := map[*ast.CommentGroup]bool{}
+	for _, s := range d.Specs {
+		spec := s.(*ast.ImportSpec)
+		attached[spec.Doc], attached[spec.Comment] = true, true
 	}
 
-	// std
-	if stdLibs[strings.ToLower(importPath)] {
-		appendTo(0)
-		return
+	for _, cg := range f.Comments {
+		if !inside(cg.Pos()) || attached[cg] {
+			continue
+		}
+
+		var next *ast.ImportSpec
+		for _, s := range d.Specs {
+			if s.Pos() > cg.Pos() {
+				next = s.(*ast.ImportSpec)
+				break
+			}
+		}
+		if next == nil {
+			trailing = append(trailing, cg)
+			continue
+		}
+		leading[next] = append(leading[next], cg)
 	}
 
-	// local
-	if strings.Index(strings.ToLower(dir), strings.ToLower(importPath)) > -1 {
-		appendTo(2)
-		return
+	return leading, trailing
+}
+
+func (group *groupSet) sort() {
+	for _, deps := range group.groups {
+		sort.SliceStable(deps, func(i, j int) bool {
+			pi, _ := strconv.Unquote(deps[i].Path.Value)
+			pj, _ := strconv.Unquote(deps[j].Path.Value)
+			if pi != pj {
+				return pi < pj
+			}
+			return importName(deps[i]) < importName(deps[j])
+		})
 	}
+}
 
-	// vendor
-	appendTo(1)
-	return
+func (group *groupSet) specs() []ast.Spec {
+	specs := make([]ast.Spec, 0)
+	for _, deps := range group.groups {
+		for _, spec := range deps {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
 }
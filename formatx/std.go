@@ -0,0 +1,105 @@
+package formatx
+
+import (
+	_ "embed"
+	"go/version"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//go:generate sh -c "(echo \"# $(go env GOVERSION)\"; go list std | grep -v ^vendor/) > std_packages.txt"
+
+//go:embed std_packages.txt
+var stdPackagesList string
+
+var (
+	stdPackages        map[string]bool
+	stdPackagesVersion string
+	stdPackagesOnce    sync.Once
+
+	toolchainStdPackages     map[string]bool
+	toolchainStdPackagesOnce sync.Once
+)
+
+func embeddedStdPackages() map[string]bool {
+	stdPackagesOnce.Do(func() {
+		stdPackages = map[string]bool{}
+		for _, line := range strings.Split(stdPackagesList, "\n") {
+			if v, ok := strings.CutPrefix(line, "# "); ok {
+				stdPackagesVersion = strings.TrimSpace(v)
+				continue
+			}
+			if importPath := strings.TrimSpace(line); importPath != "" {
+				stdPackages[importPath] = true
+			}
+		}
+	})
+	return stdPackages
+}
+
+// toolchainStdPackages are only consulted when the running toolchain may ship packages the embedded list lacks
+func needToolchainStdPackages() bool {
+	embeddedStdPackages()
+	return isNewerGoVersion(runtime.Version(), stdPackagesVersion)
+}
+
+func isNewerGoVersion(v string, than string) bool {
+	if !version.IsValid(v) || !version.IsValid(than) {
+		return true
+	}
+	return version.Compare(v, than) > 0
+}
+
+func localStdPackages() map[string]bool {
+	toolchainStdPackagesOnce.Do(func() {
+		toolchainStdPackages = map[string]bool{}
+
+		out, err := exec.Command("go", "list", "std").Output()
+		if err != nil {
+			return
+		}
+
+		for _, importPath := range strings.Fields(string(out)) {
+			if !strings.HasPrefix(importPath, "vendor/") {
+				toolchainStdPackages[importPath] = true
+			}
+		}
+	})
+	return toolchainStdPackages
+}
+
+func IsStdPackage(importPath string) bool {
+	if embeddedStdPackages()[importPath] {
+		return true
+	}
+
+	// std packages never contain a dot in the first path element
+	if first, _, _ := strings.Cut(importPath, "/"); first == "" || strings.Contains(first, ".") {
+		return false
+	}
+
+	return needToolchainStdPackages() && localStdPackages()[importPath]
+}
+
+func StdPackages() []string {
+	set := map[string]bool{}
+	for importPath := range embeddedStdPackages() {
+		set[importPath] = true
+	}
+	if needToolchainStdPackages() {
+		for importPath := range localStdPackages() {
+			set[importPath] = true
+		}
+	}
+
+	list := make([]string, 0, len(set))
+	for importPath := range set {
+		list = append(list, importPath)
+	}
+	sort.Strings(list)
+
+	return list
+}
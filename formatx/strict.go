@@ -0,0 +1,233 @@
+package formatx
+
+import (
+	"go/ast"
+	"go/token"
+	"path/filepath"
+
+	"golang.org/x/mod/semver"
+)
+
+var StrictProcesses = []Process{
+	NoEmptyLinesAtBlockEdgesProcess,
+	GroupVarDeclsProcess,
+	OctalLiteralsProcess,
+	ShortCaseClausesProcess,
+}
+
+func StrictProcess(fset *token.FileSet, f *ast.File, filename string) error {
+	for _, process := range StrictProcesses {
+		if err := process(fset, f, filename); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func NoEmptyLinesAtBlockEdgesProcess(fset *token.FileSet, f *ast.File, filename string) error {
+	tf := fset.File(f.Pos())
+	if tf == nil {
+		return nil
+	}
+
+	trim := func(open token.Pos, close token.Pos, nodes ...ast.Node) {
+		if !open.IsValid() || !close.IsValid() {
+			return
+		}
+
+		first, last := token.NoPos, token.NoPos
+
+		if len(nodes) > 0 {
+			first, last = nodes[0].Pos(), nodes[len(nodes)-1].End()
+		}
+
+		for _, cg := range f.Comments {
+			if cg.Pos() > open && cg.End() < close {
+				if !first.IsValid() || cg.Pos() < first {
+					first = cg.Pos()
+				}
+				if cg.End() > last {
+					last = cg.End()
+				}
+			}
+		}
+
+		if !first.IsValid() {
+			for tf.Line(close)-tf.Line(open) > 1 {
+				tf.MergeLine(tf.Line(open) + 1)
+			}
+			return
+		}
+
+		for tf.Line(first)-tf.Line(open) > 1 {
+			tf.MergeLine(tf.Line(open) + 1)
+		}
+
+		for tf.Line(close)-tf.Line(last) > 1 {
+			tf.MergeLine(tf.Line(close) - 1)
+		}
+	}
+
+	ast.Inspect(f, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.BlockStmt:
+			trim(n.Lbrace, n.Rbrace, stmtNodes(n.List)...)
+
+			// bodies of case and comm clauses end where the next clause or the switch ends
+			for i, stmt := range n.List {
+				close := n.Rbrace
+				if i+1 < len(n.List) {
+					close = n.List[i+1].Pos()
+				}
+
+				switch clause := stmt.(type) {
+				case *ast.CaseClause:
+					trim(clause.Colon, close, stmtNodes(clause.Body)...)
+				case *ast.CommClause:
+					trim(clause.Colon, close, stmtNodes(clause.Body)...)
+				}
+			}
+		case *ast.FieldList:
+			nodes := make([]ast.Node, 0, len(n.List))
+			for _, field := range n.List {
+				nodes = append(nodes, field)
+			}
+			trim(n.Opening, n.Closing, nodes...)
+		case *ast.CompositeLit:
+			nodes := make([]ast.Node, 0, len(n.Elts))
+			for _, elt := range n.Elts {
+				nodes = append(nodes, elt)
+			}
+			trim(n.Lbrace, n.Rbrace, nodes...)
+		}
+		return true
+	})
+
+	return nil
+}
+
+func stmtNodes(stmts []ast.Stmt) []ast.Node {
+	nodes := make([]ast.Node, 0, len(stmts))
+	for _, stmt := range stmts {
+		nodes = append(nodes, stmt)
+	}
+	return nodes
+}
+
+func GroupVarDeclsProcess(fset *token.FileSet, f *ast.File, filename string) error {
+	decls := make([]ast.Decl, 0, len(f.Decls))
+
+	var group *ast.GenDecl
+	var groupEnd token.Pos
+
+	for _, decl := range f.Decls {
+		d, ok := decl.(*ast.GenDecl)
+		if !ok || d.Tok != token.VAR || d.Lparen.IsValid() {
+			group = nil
+			decls = append(decls, decl)
+			continue
+		}
+
+		if group != nil && d.Doc == nil && fset.Position(d.Pos()).Line == fset.Position(groupEnd).Line+1 && !hasCommentBetween(f, groupEnd, d.Pos()) {
+			if !group.Lparen.IsValid() {
+				group.Lparen = group.TokPos + token.Pos(len(token.VAR.String()))
+			}
+			group.Specs = append(group.Specs, d.Specs...)
+			groupEnd = declEnd(d)
+			group.Rparen = groupEnd
+			continue
+		}
+
+		group, groupEnd = d, declEnd(d)
+		decls = append(decls, decl)
+	}
+
+	f.Decls = decls
+
+	return nil
+}
+
+func declEnd(d *ast.GenDecl) token.Pos {
+	end := d.End()
+	for _, spec := range d.Specs {
+		if vs, ok := spec.(*ast.ValueSpec); ok && vs.Comment != nil && vs.Comment.End() > end {
+			end = vs.Comment.End()
+		}
+	}
+	return end
+}
+
+func hasCommentBetween(f *ast.File, from token.Pos, to token.Pos) bool {
+	for _, cg := range f.Comments {
+		if cg.Pos() >= from && cg.End() <= to {
+			return true
+		}
+	}
+	return false
+}
+
+func OctalLiteralsProcess(fset *token.FileSet, f *ast.File, filename string) error {
+	if m, err := ModuleOf(filepath.Dir(filename)); err == nil && m.GoVersion != "" && semver.Compare("v"+m.GoVersion, "v1.13") < 0 {
+		return nil
+	}
+
+	ast.Inspect(f, func(node ast.Node) bool {
+		if lit, ok := node.(*ast.BasicLit); ok && lit.Kind == token.INT && isLegacyOctal(lit.Value) {
+			lit.Value = "0o" + lit.Value[1:]
+		}
+		return true
+	})
+
+	return nil
+}
+
+func isLegacyOctal(value string) bool {
+	if len(value) < 2 || value[0] != '0' {
+		return false
+	}
+	for _, c := range value[1:] {
+		if (c < '0' || c > '7') && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+const shortCaseClauseWidth = 80
+
+func ShortCaseClausesProcess(fset *token.FileSet, f *ast.File, filename string) error {
+	tf := fset.File(f.Pos())
+	if tf == nil {
+		return nil
+	}
+
+	join := func(caseToken token.Pos, list []ast.Expr, colon token.Pos) {
+		if len(list) == 0 || tf.Line(caseToken) == tf.Line(colon) || hasCommentBetween(f, caseToken, colon) {
+			return
+		}
+
+		width := len("case :") + 2*(len(list)-1)
+		for _, expr := range list {
+			if tf.Line(expr.Pos()) != tf.Line(expr.End()) {
+				return
+			}
+			width += tf.Offset(expr.End()) - tf.Offset(expr.Pos())
+		}
+		if width > shortCaseClauseWidth {
+			return
+		}
+
+		for tf.Line(colon) > tf.Line(caseToken) {
+			tf.MergeLine(tf.Line(caseToken))
+		}
+	}
+
+	ast.Inspect(f, func(node ast.Node) bool {
+		if clause, ok := node.(*ast.CaseClause); ok {
+			join(clause.Case, clause.List, clause.Colon)
+		}
+		return true
+	})
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package formatx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrictProcess(t *testing.T) {
+	result, err := Format("strict.go", []byte(`package strict
+
+var a = 1
+var b = 0755 // mode
+var c = 0 // c
+
+var d = 0
+
+type S struct {
+
+	A string
+
+}
+
+func F(v int, ch chan int) int {
+
+	// leading
+	x := []int{
+
+		1,
+
+	}
+
+	switch v {
+	case 1,
+		2,
+		3:
+
+		return x[0]
+
+	case 0x10, 010:
+	}
+
+	select {
+	case <-ch:
+
+		return 1
+
+	default:
+	}
+
+	if v > 0 {
+		return 0644
+
+	}
+
+	return 0
+}
+`), StrictProcess)
+
+	require.NoError(t, err)
+	require.Equal(t, `package strict
+
+var (
+	a = 1
+	b = 0o755 // mode
+	c = 0     // c
+)
+
+var d = 0
+
+type S struct {
+	A string
+}
+
+func F(v int, ch chan int) int {
+	// leading
+	x := []int{
+		1,
+	}
+
+	switch v {
+	case 1, 2, 3:
+		return x[0]
+	case 0x10, 0o10:
+	}
+
+	select {
+	case <-ch:
+		return 1
+	default:
+	}
+
+	if v > 0 {
+		return 0o644
+	}
+
+	return 0
+}
+`, string(result))
+}
+
+func TestShortCaseClausesProcess_LongClause(t *testing.T) {
+	src := `package strict
+
+func F(v string) {
+	switch v {
+	case "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb",
+		"cccccccccccccccccccccccccccccccccc":
+	}
+}
+`
+
+	result, err := Format("strict.go", []byte(src), ShortCaseClausesProcess)
+	require.NoError(t, err)
+	require.Equal(t, src, string(result))
+}
@@ -1,10 +1,15 @@
 module github.com/go-courier/codegen
 
-go 1.16
+go 1.22.0
 
 require (
-	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0
 	github.com/stretchr/testify v1.3.0
-	golang.org/x/tools v0.1.2 // indirect
+	golang.org/x/mod v0.21.0
+	golang.org/x/tools v0.26.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	golang.org/x/sync v0.8.0 // indirect
 )
@@ -0,0 +1,265 @@
+package codegen
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type OutputFS interface {
+	ReadFile(filename string) ([]byte, error)
+	WriteFile(filename string, data []byte) error
+	Remove(filename string) error
+	ReadDir(dir string) ([]string, error)
+}
+
+type writeStatus int
+
+const (
+	writeUnchanged writeStatus = iota
+	writeCreated
+	writeUpdated
+)
+
+func writeFileIfChanged(fs OutputFS, filename string, data []byte) (writeStatus, error) {
+	existing, err := fs.ReadFile(filename)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return writeUnchanged, err
+		}
+		return writeCreated, fs.WriteFile(filename, data)
+	}
+
+	if bytes.Equal(existing, data) {
+		return writeUnchanged, nil
+	}
+
+	return writeUpdated, fs.WriteFile(filename, data)
+}
+
+type OSFS struct{}
+
+func (OSFS) ReadFile(filename string) ([]byte, error) {
+	return os.ReadFile(filename)
+}
+
+func (OSFS) WriteFile(filename string, data []byte) error {
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(filename); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	dir := filepath.Dir(filename)
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(f.Name(), perm); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filename)
+}
+
+func (OSFS) Remove(filename string) error {
+	return os.Remove(filename)
+}
+
+func (OSFS) ReadDir(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+func NewMemFS() *MemFS {
+	return &MemFS{
+		files: map[string][]byte{},
+	}
+}
+
+type MemFS struct {
+	mu    sync.RWMutex
+	files map[string][]byte
+}
+
+func (m *MemFS) ReadFile(filename string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	data, ok := m.files[filepath.Clean(filename)]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: os.ErrNotExist}
+	}
+	return append([]byte{}, data...), nil
+}
+
+func (m *MemFS) WriteFile(filename string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.files[filepath.Clean(filename)] = append([]byte{}, data...)
+	return nil
+}
+
+func (m *MemFS) Remove(filename string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	filename = filepath.Clean(filename)
+	if _, ok := m.files[filename]; !ok {
+		return &os.PathError{Op: "remove", Path: filename, Err: os.ErrNotExist}
+	}
+	delete(m.files, filename)
+	return nil
+}
+
+func (m *MemFS) ReadDir(dir string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	dir = filepath.Clean(dir)
+
+	names := make([]string, 0)
+	for filename := range m.files {
+		if filepath.Dir(filename) == dir {
+			names = append(names, filepath.Base(filename))
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (m *MemFS) Filenames() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	filenames := make([]string, 0, len(m.files))
+	for filename := range m.files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	return filenames
+}
+
+func NewTarFS(w io.Writer) *ArchiveFS {
+	return &ArchiveFS{
+		MemFS: NewMemFS(),
+		writeTo: func(filenames []string, read func(filename string) []byte) error {
+			tw := tar.NewWriter(w)
+
+			for _, filename := range filenames {
+				data := read(filename)
+
+				if err := tw.WriteHeader(&tar.Header{
+					Typeflag: tar.TypeReg,
+					Name:     archiveName(filename),
+					Mode:     0644,
+					Size:     int64(len(data)),
+					ModTime:  archiveModTime,
+				}); err != nil {
+					return err
+				}
+				if _, err := tw.Write(data); err != nil {
+					return err
+				}
+			}
+
+			return tw.Close()
+		},
+	}
+}
+
+func NewZipFS(w io.Writer) *ArchiveFS {
+	return &ArchiveFS{
+		MemFS: NewMemFS(),
+		writeTo: func(filenames []string, read func(filename string) []byte) error {
+			zw := zip.NewWriter(w)
+
+			for _, filename := range filenames {
+				f, err := zw.CreateHeader(&zip.FileHeader{
+					Name:     archiveName(filename),
+					Method:   zip.Deflate,
+					Modified: archiveModTime,
+				})
+				if err != nil {
+					return err
+				}
+				if _, err := f.Write(read(filename)); err != nil {
+					return err
+				}
+			}
+
+			return zw.Close()
+		},
+	}
+}
+
+var archiveModTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func archiveName(filename string) string {
+	return strings.TrimPrefix(filepath.ToSlash(filepath.Clean(filename)), "/")
+}
+
+type ArchiveFS struct {
+	*MemFS
+	writeTo func(filenames []string, read func(filename string) []byte) error
+}
+
+func (a *ArchiveFS) ReadFile(filename string) ([]byte, error) {
+	return nil, &os.PathError{Op: "open", Path: filename, Err: os.ErrNotExist}
+}
+
+func (a *ArchiveFS) ReadDir(dir string) ([]string, error) {
+	return nil, nil
+}
+
+func (a *ArchiveFS) Close() error {
+	filenames := a.Filenames()
+	sort.Slice(filenames, func(i, j int) bool {
+		return archiveName(filenames[i]) < archiveName(filenames[j])
+	})
+
+	return a.writeTo(filenames, func(filename string) []byte {
+		data, _ := a.MemFS.ReadFile(filename)
+		return data
+	})
+}
@@ -0,0 +1,167 @@
+package codegen
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOSFS(t *testing.T) {
+	tt := require.New(t)
+
+	filename := filepath.Join(t.TempDir(), "a", "a.go")
+
+	fs := OSFS{}
+
+	tt.NoError(fs.WriteFile(filename, []byte("package a\n")))
+	tt.NoError(os.Chmod(filename, 0600))
+	tt.NoError(fs.WriteFile(filename, []byte("package a\n\n")))
+
+	data, err := fs.ReadFile(filename)
+	tt.NoError(err)
+	tt.Equal("package a\n\n", string(data))
+
+	info, err := os.Stat(filename)
+	tt.NoError(err)
+	tt.Equal(os.FileMode(0600), info.Mode().Perm())
+
+	names, err := fs.ReadDir(filepath.Dir(filename))
+	tt.NoError(err)
+	tt.Equal([]string{"a.go"}, names)
+
+	tt.NoError(fs.Remove(filename))
+	_, err = fs.ReadFile(filename)
+	tt.True(os.IsNotExist(err))
+}
+
+func TestMemFS(t *testing.T) {
+	tt := require.New(t)
+
+	fs := NewMemFS()
+
+	_, err := fs.ReadFile("a/a.go")
+	tt.True(os.IsNotExist(err))
+
+	tt.NoError(fs.WriteFile("a/a.go", []byte("package a\n")))
+	tt.NoError(fs.WriteFile("a/b.go", []byte("package a\n")))
+	tt.NoError(fs.WriteFile("a/b/b.go", []byte("package b\n")))
+
+	data, err := fs.ReadFile("./a/a.go")
+	tt.NoError(err)
+	tt.Equal("package a\n", string(data))
+
+	names, err := fs.ReadDir("a")
+	tt.NoError(err)
+	tt.Equal([]string{"a.go", "b.go"}, names)
+
+	tt.NoError(fs.Remove("a/b.go"))
+	tt.True(os.IsNotExist(fs.Remove("a/b.go")))
+
+	tt.Equal([]string{"a/a.go", "a/b/b.go"}, fs.Filenames())
+}
+
+func TestFile_WithFS(t *testing.T) {
+	tt := require.New(t)
+
+	fs := NewMemFS()
+
+	file := NewFile("main", "main/main.go").WithResolver(StaticPackageResolver{}).WithFS(fs)
+	file.WriteBlock(Func().Named("main").Do())
+
+	_, err := file.WriteFile()
+	tt.NoError(err)
+
+	data, err := fs.ReadFile("main/main.go")
+	tt.NoError(err)
+	tt.Equal(file.Bytes(), data)
+
+	_, err = os.Stat("main/main.go")
+	tt.True(os.IsNotExist(err))
+
+	result, err := file.Check()
+	tt.NoError(err)
+	tt.True(result.Passed())
+}
+
+func TestProject_WithFS(t *testing.T) {
+	tt := require.New(t)
+
+	fs := NewMemFS()
+	tt.NoError(fs.WriteFile("pkg/stale__generated.go", []byte("// Code generated by gen. DO NOT EDIT.\n\npackage pkg\n")))
+	tt.NoError(fs.WriteFile("pkg/handwritten.go", []byte("package pkg\n")))
+
+	p := NewProject("gen").WithResolver(StaticPackageResolver{}).WithFS(fs)
+	p.NewFile("pkg", "pkg/a__generated.go").WriteBlock(DeclConst(Assign(Id("A")).By(Val(1))))
+
+	summary, err := p.WriteFiles()
+	tt.NoError(err)
+	tt.Equal([]string{"pkg/a__generated.go"}, summary.Created)
+	tt.Equal([]string{"pkg/stale__generated.go"}, summary.Removed)
+
+	tt.Equal([]string{"pkg/a__generated.go", "pkg/handwritten.go"}, fs.Filenames())
+}
+
+func TestArchiveFS(t *testing.T) {
+	newProject := func(fs OutputFS) *Project {
+		p := NewProject("gen").WithResolver(StaticPackageResolver{}).WithFS(fs)
+		p.NewFile("b", "/b/b__generated.go").WriteBlock(DeclConst(Assign(Id("B")).By(Val(1))))
+		p.NewFile("a", "a/a__generated.go").WriteBlock(DeclConst(Assign(Id("A")).By(Val(1))))
+		return p
+	}
+
+	t.Run("tar", func(t *testing.T) {
+		tt := require.New(t)
+
+		buf := &bytes.Buffer{}
+		fs := NewTarFS(buf)
+
+		_, err := newProject(fs).WriteFiles()
+		tt.NoError(err)
+		tt.NoError(fs.Close())
+
+		tr := tar.NewReader(buf)
+		names := make([]string, 0)
+
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			tt.NoError(err)
+			names = append(names, hdr.Name)
+
+			data, err := io.ReadAll(tr)
+			tt.NoError(err)
+			tt.Contains(string(data), "// Code generated by gen. DO NOT EDIT.")
+		}
+
+		tt.Equal([]string{"a/a__generated.go", "b/b__generated.go"}, names)
+	})
+
+	t.Run("zip", func(t *testing.T) {
+		tt := require.New(t)
+
+		buf := &bytes.Buffer{}
+		fs := NewZipFS(buf)
+
+		_, err := newProject(fs).WriteFiles()
+		tt.NoError(err)
+		tt.NoError(fs.Close())
+
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		tt.NoError(err)
+
+		names := make([]string, 0)
+		for _, f := range zr.File {
+			names = append(names, f.Name)
+		}
+
+		tt.Equal([]string{"a/a__generated.go", "b/b__generated.go"}, names)
+	})
+}
@@ -0,0 +1,53 @@
+package codegen
+
+import (
+	"runtime"
+	"sync"
+)
+
+func RenderFiles(workers int, files ...*File) ([][]byte, error) {
+	results := make([][]byte, len(files))
+
+	err := parallel(workers, len(files), func(i int) error {
+		data, err := files[i].Render()
+		results[i] = data
+		return err
+	})
+
+	return results, err
+}
+
+func parallel(workers int, n int, fn func(i int) error) error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	errs := make([]error, n)
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+
+	for w := 0; w < workers && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
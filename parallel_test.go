@@ -0,0 +1,180 @@
+package codegen
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-courier/codegen/formatx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFile_Concurrency(t *testing.T) {
+	tt := require.New(t)
+
+	file := NewFile("main", "main.go").WithResolver(StaticPackageResolver{})
+
+	importPaths := []string{"fmt", "strings", "bytes", "github.com/x/errors", "github.com/y/errors"}
+
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			importPath := importPaths[i%len(importPaths)]
+			if i < 5 {
+				file.Import(Import(fmt.Sprintf("github.com/drivers/driver%d", i)).AsBlank())
+			}
+			file.WriteBlock(
+				DeclVar(Assign(Id(fmt.Sprintf("v%d", i))).By(Id(file.Use(importPath, "Value")))),
+			)
+		}(i)
+	}
+
+	wg.Wait()
+
+	data, err := file.Render()
+	tt.NoError(err)
+
+	_, f, err := formatx.ParseFile("main.go", data)
+	tt.NoError(err)
+	tt.Len(f.Imports, 10)
+	tt.Len(f.Decls, 51)
+}
+
+type barrierResolver struct {
+	wg *sync.WaitGroup
+}
+
+func (r barrierResolver) Resolve(importPaths ...string) ([]*Package, error) {
+	r.wg.Done()
+	r.wg.Wait()
+	return StaticPackageResolver{}.Resolve(importPaths...)
+}
+
+func TestFile_ResolveWithoutLock(t *testing.T) {
+	tt := require.New(t)
+
+	barrier := &sync.WaitGroup{}
+	barrier.Add(2)
+
+	file := NewFile("main", "main.go").WithResolver(barrierResolver{wg: barrier})
+
+	done := make(chan bool)
+	for _, importPath := range []string{"fmt", "strings"} {
+		go func(importPath string) {
+			file.Use(importPath, "Value")
+			done <- true
+		}(importPath)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			tt.FailNow("resolving imports is serialized by the file lock")
+		}
+	}
+}
+
+func TestRenderFiles(t *testing.T) {
+	tt := require.New(t)
+
+	files := make([]*File, 0)
+
+	for i := 0; i < 20; i++ {
+		file := NewFile("pkg", fmt.Sprintf("file%d.go", i))
+		file.WriteBlock(DeclConst(Assign(Id("Index")).By(Val(i))))
+		files = append(files, file)
+	}
+
+	results, err := RenderFiles(4, files...)
+	tt.NoError(err)
+	tt.Len(results, 20)
+
+	for i := range results {
+		tt.Equal(fmt.Sprintf("package pkg\n\nconst Index = %d\n", i), string(results[i]))
+	}
+
+	broken := NewFile("pkg", "broken.go")
+	broken.WriteBlock(Expr("const ="))
+
+	_, err = RenderFiles(4, append(files, broken)...)
+	tt.Error(err)
+}
+
+func TestProject_WithWorkers(t *testing.T) {
+	tt := require.New(t)
+
+	dir := t.TempDir()
+
+	p := NewProject("gen").WithWorkers(3)
+
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p.NewFile("pkg", filepath.Join(dir, fmt.Sprintf("file%d__generated.go", i))).WriteBlock(
+				DeclConst(Assign(Id("Index")).By(Val(i))),
+			)
+		}(i)
+	}
+
+	wg.Wait()
+
+	summary, err := p.WriteFiles()
+	tt.NoError(err)
+	tt.Len(summary.Created, 10)
+	tt.Equal(filepath.Join(dir, "file0__generated.go"), summary.Created[0])
+	tt.Equal(filepath.Join(dir, "file9__generated.go"), summary.Created[9])
+
+	result, err := p.Check()
+	tt.NoError(err)
+	tt.True(result.Passed())
+}
+
+func TestFile_Concurrency_ClashingNames(t *testing.T) {
+	tt := require.New(t)
+
+	render := func() string {
+		file := NewFile("main", "main.go").WithResolver(StaticPackageResolver{})
+		file.Import(Import("text/template"), Import("html/template"))
+
+		wg := sync.WaitGroup{}
+		values := make([]Snippet, 20)
+
+		for i := range values {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+
+				importPath := "text/template"
+				if i%2 == 1 {
+					importPath = "html/template"
+				}
+				values[i] = DeclVar(Assign(Id(fmt.Sprintf("v%d", i))).By(Id(file.Use(importPath, "New"))))
+			}(i)
+		}
+
+		wg.Wait()
+		file.WriteBlock(values...)
+
+		data, err := file.Render()
+		tt.NoError(err)
+		return string(data)
+	}
+
+	expected := render()
+	tt.Contains(expected, `htmltemplate "html/template"`)
+	tt.Contains(expected, "var v1 = htmltemplate.New")
+
+	for i := 0; i < 20; i++ {
+		tt.Equal(expected, render())
+	}
+}
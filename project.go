@@ -0,0 +1,221 @@
+package codegen
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/go-courier/codegen/formatx"
+)
+
+func NewProject(generator string) *Project {
+	return &Project{
+		generator: generator,
+		files:     map[string]*File{},
+	}
+}
+
+type Project struct {
+	generator string
+	resolver  PackageResolver
+	typeCheck bool
+	workers   int
+	fs        OutputFS
+	processes []formatx.Process
+	files     map[string]*File
+	mu        sync.Mutex
+}
+
+func (p *Project) WithResolver(resolver PackageResolver) *Project {
+	p.resolver = resolver
+	return p
+}
+
+func (p *Project) WithTypeCheck(typeCheck bool) *Project {
+	p.typeCheck = typeCheck
+	return p
+}
+
+func (p *Project) WithWorkers(workers int) *Project {
+	p.workers = workers
+	return p
+}
+
+func (p *Project) WithFS(fs OutputFS) *Project {
+	p.fs = fs
+	return p
+}
+
+func (p *Project) WithProcesses(processes ...formatx.Process) *Project {
+	p.processes = append(p.processes, processes...)
+	return p
+}
+
+// Prefetch resolves the import paths used across the files of the project in one batch
+func (p *Project) Prefetch(importPaths ...string) error {
+	resolver := p.resolver
+	if resolver == nil {
+		resolver = DefaultPackageResolver
+	}
+	_, err := resolver.Resolve(importPaths...)
+	return err
+}
+
+func (p *Project) outputFS() OutputFS {
+	if p.fs == nil {
+		return OSFS{}
+	}
+	return p.fs
+}
+
+func (p *Project) NewFile(pkgName string, filename string) *File {
+	filename = filepath.Clean(filename)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if file, ok := p.files[filename]; ok {
+		return file
+	}
+
+	file := NewFile(pkgName, filename).WithGenerator(p.generator).WithResolver(p.resolver).WithFS(p.fs).WithProcesses(p.processes...)
+	p.files[filename] = file
+	return file
+}
+
+func (p *Project) Files() []*File {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	filenames := make([]string, 0, len(p.files))
+	for filename := range p.files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	files := make([]*File, len(filenames))
+	for i := range filenames {
+		files[i] = p.files[filenames[i]]
+	}
+	return files
+}
+
+type WriteSummary struct {
+	Created   []string
+	Updated   []string
+	Unchanged []string
+	Removed   []string
+}
+
+func (p *Project) WriteFiles() (*WriteSummary, error) {
+	summary := &WriteSummary{}
+
+	files := p.Files()
+
+	if p.typeCheck {
+		if err := typeCheckFiles(files...); err != nil {
+			return summary, err
+		}
+	}
+
+	statuses := make([]writeStatus, len(files))
+
+	err := parallel(p.workers, len(files), func(i int) error {
+		data, err := files[i].Render()
+		if err != nil {
+			return err
+		}
+		statuses[i], err = writeFileIfChanged(p.outputFS(), files[i].filename, data)
+		return err
+	})
+	if err != nil {
+		return summary, err
+	}
+
+	for i, file := range files {
+		switch statuses[i] {
+		case writeCreated:
+			summary.Created = append(summary.Created, file.filename)
+		case writeUpdated:
+			summary.Updated = append(summary.Updated, file.filename)
+		default:
+			summary.Unchanged = append(summary.Unchanged, file.filename)
+		}
+	}
+
+	staleFiles, err := p.staleFiles()
+	if err != nil {
+		return summary, err
+	}
+
+	for _, filename := range staleFiles {
+		if err := p.outputFS().Remove(filename); err != nil {
+			return summary, err
+		}
+		summary.Removed = append(summary.Removed, filename)
+	}
+
+	return summary, nil
+}
+
+func (p *Project) staleFiles() ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	dirs := map[string]bool{}
+	for filename := range p.files {
+		dirs[filepath.Dir(filename)] = true
+	}
+
+	staleFiles := make([]string, 0)
+
+	for dir := range dirs {
+		names, err := p.outputFS().ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		for _, name := range names {
+			filename := filepath.Join(dir, name)
+
+			if !IsGoFile(filename) || p.files[filename] != nil {
+				continue
+			}
+
+			isStale, err := p.isGeneratedFile(filename)
+			if err != nil {
+				return nil, err
+			}
+			if isStale {
+				staleFiles = append(staleFiles, filename)
+			}
+		}
+	}
+
+	sort.Strings(staleFiles)
+
+	return staleFiles, nil
+}
+
+// isGeneratedFile reports whether filename was written by the generator of p, so files of other
+// generators sharing the package survive even when they use the __generated suffix too.
+func (p *Project) isGeneratedFile(filename string) (bool, error) {
+	if p.generator == "" && !IsGeneratedFile(filename) {
+		return false, nil
+	}
+
+	data, err := p.outputFS().ReadFile(filename)
+	if err != nil {
+		return false, err
+	}
+
+	if p.generator == "" {
+		return !IsGenerated(data), nil
+	}
+
+	return isGeneratedBy(data, p.generator), nil
+}
@@ -0,0 +1,79 @@
+package codegen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProject_WriteFiles(t *testing.T) {
+	tt := require.New(t)
+
+	dir := t.TempDir()
+
+	writeFile := func(name string, content string) {
+		tt.NoError(os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	writeFile("stale__generated.go", "// Code generated by gen. DO NOT EDIT.\n\npackage pkg\n")
+	writeFile("enum__generated.go", "// Code generated by enumgen. DO NOT EDIT.\n\npackage pkg\n")
+	writeFile("stale.go", "// Code generated by gen. DO NOT EDIT.\n\npackage pkg\n")
+	writeFile("stringer.go", "// Code generated by stringer. DO NOT EDIT.\n\npackage pkg\n")
+	writeFile("handwritten.go", "package pkg\n")
+	writeFile("handwritten__generated_helpers.go", "package pkg\n")
+
+	newProject := func(value string) *Project {
+		p := NewProject("gen").WithResolver(StaticPackageResolver{})
+
+		a := p.NewFile("pkg", filepath.Join(dir, "a__generated.go"))
+		a.WriteBlock(DeclConst(Assign(Id("A")).By(Val(value))))
+
+		b := p.NewFile("pkg", filepath.Join(dir, "b__generated.go"))
+		b.WriteBlock(DeclConst(Assign(Id("B")).By(Val("b"))))
+
+		tt.True(a == p.NewFile("pkg", filepath.Join(dir, ".", "a__generated.go")))
+
+		return p
+	}
+
+	summary, err := newProject("a").WriteFiles()
+	tt.NoError(err)
+	tt.Equal(&WriteSummary{
+		Created: []string{filepath.Join(dir, "a__generated.go"), filepath.Join(dir, "b__generated.go")},
+		Removed: []string{filepath.Join(dir, "stale.go"), filepath.Join(dir, "stale__generated.go")},
+	}, summary)
+
+	summary, err = newProject("a2").WriteFiles()
+	tt.NoError(err)
+	tt.Equal(&WriteSummary{
+		Updated:   []string{filepath.Join(dir, "a__generated.go")},
+		Unchanged: []string{filepath.Join(dir, "b__generated.go")},
+	}, summary)
+
+	entries, err := os.ReadDir(dir)
+	tt.NoError(err)
+
+	names := make([]string, 0)
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	tt.Equal([]string{"a__generated.go", "b__generated.go", "enum__generated.go", "handwritten.go", "handwritten__generated_helpers.go", "stringer.go"}, names)
+}
+
+func TestProject_WriteFiles_WithoutGenerator(t *testing.T) {
+	tt := require.New(t)
+
+	dir := t.TempDir()
+
+	tt.NoError(os.WriteFile(filepath.Join(dir, "stale__generated.go"), []byte("package pkg\n"), 0644))
+	tt.NoError(os.WriteFile(filepath.Join(dir, "enum__generated.go"), []byte("// Code generated by enumgen. DO NOT EDIT.\n\npackage pkg\n"), 0644))
+
+	p := NewProject("").WithResolver(StaticPackageResolver{})
+	p.NewFile("pkg", filepath.Join(dir, "a__generated.go")).WriteBlock(DeclConst(Assign(Id("A")).By(Val(1))))
+
+	summary, err := p.WriteFiles()
+	tt.NoError(err)
+	tt.Equal([]string{filepath.Join(dir, "stale__generated.go")}, summary.Removed)
+}
@@ -0,0 +1,639 @@
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-courier/codegen/formatx"
+)
+
+const (
+	regionBeginMarker = "// codegen:begin "
+	regionEndMarker   = "// codegen:end"
+)
+
+func NewRegionFile(filename string) *RegionFile {
+	return &RegionFile{
+		filename: filename,
+		regions:  map[string]*Region{},
+	}
+}
+
+type RegionFile struct {
+	filename string
+	fs       OutputFS
+	resolver PackageResolver
+	pkgPath  *string
+	regions  map[string]*Region
+	order    []string
+
+	file     *File
+	src      []byte
+	existing map[string]bool
+	loadErr  error
+	loadOnce sync.Once
+	mu       sync.Mutex
+}
+
+func (f *RegionFile) WithFS(fs OutputFS) *RegionFile {
+	f.fs = fs
+	return f
+}
+
+func (f *RegionFile) WithResolver(resolver PackageResolver) *RegionFile {
+	f.resolver = resolver
+	return f
+}
+
+func (f *RegionFile) WithPkgPath(pkgPath string) *RegionFile {
+	f.pkgPath = &pkgPath
+	return f
+}
+
+func (f *RegionFile) outputFS() OutputFS {
+	if f.fs == nil {
+		return OSFS{}
+	}
+	return f.fs
+}
+
+func (f *RegionFile) Region(name string) *Region {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r, ok := f.regions[name]; ok {
+		return r
+	}
+
+	r := &Region{Name: name}
+	f.regions[name] = r
+	f.order = append(f.order, name)
+	return r
+}
+
+func (f *RegionFile) Expr(format string, args ...interface{}) SnippetExpr {
+	return createExpr(f.importAliaser)(format, args...)
+}
+
+func (f *RegionFile) TypeOf(tpe reflect.Type) SnippetType {
+	return createTypeOf(f.importAliaser)(tpe)
+}
+
+func (f *RegionFile) Val(v interface{}) Snippet {
+	return createVal(f.importAliaser)(v)
+}
+
+func (f *RegionFile) Use(importPath string, exposedName string) string {
+	return qualified(f.importAliaser(importPath), exposedName)
+}
+
+func (f *RegionFile) importAliaser(importPath string) string {
+	if err := f.load(); err != nil {
+		return formatx.GuessPkgName(importPath)
+	}
+	return f.file.importAliaser(importPath)
+}
+
+func (f *RegionFile) load() error {
+	f.loadOnce.Do(func() {
+		src, err := f.outputFS().ReadFile(f.filename)
+		if err != nil {
+			f.loadErr = err
+			return
+		}
+
+		astFile, err := parser.ParseFile(token.NewFileSet(), f.filename, src, parser.ImportsOnly|parser.ParseComments)
+		if err != nil {
+			f.loadErr = newFileError(f.filename, src, err)
+			return
+		}
+
+		f.src = src
+		f.file = NewFile(astFile.Name.Name, f.filename).WithResolver(f.resolver)
+		f.file.PkgName = astFile.Name.Name
+		if f.pkgPath != nil {
+			f.file.WithPkgPath(*f.pkgPath)
+		}
+
+		f.existing = map[string]bool{}
+
+		for _, spec := range astFile.Imports {
+			importPath, _ := strconv.Unquote(spec.Path.Value)
+
+			if spec.Name == nil {
+				f.existing[importPath] = true
+				f.file.importAliaser(importPath)
+				continue
+			}
+
+			switch spec.Name.Name {
+			case "_":
+			case ".":
+				f.existing[importPath] = true
+				f.file.Import(Import(importPath).AsDot())
+			default:
+				f.existing[importPath] = true
+				f.file.Import(Import(importPath).As(spec.Name.Name))
+			}
+		}
+	})
+	return f.loadErr
+}
+
+func (f *RegionFile) Render() ([]byte, error) {
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+
+	f.file.mu.Lock()
+	errs := f.file.errs
+	newImports := make([]*SnippetImport, 0)
+	for importPath, alias := range f.file.imports {
+		if f.existing[importPath] {
+			continue
+		}
+		if alias == f.file.pkgNames[importPath] {
+			newImports = append(newImports, Import(importPath))
+		} else {
+			newImports = append(newImports, Import(importPath).As(alias))
+		}
+	}
+	f.file.mu.Unlock()
+
+	if len(errs) > 0 {
+		return nil, &FileError{Filename: f.filename, Err: errs[0]}
+	}
+
+	sort.Slice(newImports, func(i, j int) bool {
+		return newImports[i].Path < newImports[j].Path
+	})
+
+	edits, err := f.regionEdits()
+	if err != nil {
+		return nil, &FileError{Filename: f.filename, Err: err}
+	}
+
+	if len(newImports) > 0 {
+		importEdits, err := f.importEdits(newImports)
+		if err != nil {
+			return nil, &FileError{Filename: f.filename, Err: err}
+		}
+		edits = append(edits, importEdits...)
+	}
+
+	data := applyEdits(f.src, edits)
+
+	fset := token.NewFileSet()
+
+	astFile, err := parser.ParseFile(fset, f.filename, data, parser.AllErrors|parser.ParseComments)
+	if err != nil {
+		return nil, newFileError(f.filename, data, err)
+	}
+
+	data, err = f.formatTouchedDecls(fset, astFile, data, len(newImports) > 0)
+	if err != nil {
+		return nil, &FileError{Filename: f.filename, Err: err}
+	}
+
+	return data, nil
+}
+
+// formatTouchedDecls formats the import block when it got new specs and the written regions as
+// gofmt would inside their enclosing declarations, leaving the hand-written code around them as is.
+func (f *RegionFile) formatTouchedDecls(fset *token.FileSet, astFile *ast.File, data []byte, importsChanged bool) ([]byte, error) {
+	f.mu.Lock()
+	touched := map[ast.Decl]bool{}
+	for _, cg := range astFile.Comments {
+		for _, c := range cg.List {
+			if !strings.HasPrefix(c.Text, regionBeginMarker) || f.regions[strings.TrimSpace(strings.TrimPrefix(c.Text, regionBeginMarker))] == nil {
+				continue
+			}
+			for _, decl := range astFile.Decls {
+				if decl.Pos() <= c.Pos() && c.End() <= decl.End() {
+					touched[decl] = true
+				}
+			}
+		}
+	}
+	f.mu.Unlock()
+
+	spans, err := scanRegions(data)
+	if err != nil {
+		return nil, err
+	}
+
+	edits := make([]textEdit, 0, len(touched)+1)
+
+	for _, decl := range astFile.Decls {
+		isImport := false
+		if d, ok := decl.(*ast.GenDecl); ok && d.Tok == token.IMPORT && importsChanged {
+			isImport = true
+		}
+		if !isImport && !touched[decl] {
+			continue
+		}
+
+		buf := &bytes.Buffer{}
+		if err := format.Node(buf, fset, &printer.CommentedNode{Node: decl, Comments: astFile.Comments}); err != nil {
+			return nil, err
+		}
+
+		if isImport {
+			start := decl.Pos()
+			if d := decl.(*ast.GenDecl); d.Doc != nil {
+				start = d.Doc.Pos()
+			}
+			edits = append(edits, textEdit{Start: fset.Position(start).Offset, End: fset.Position(decl.End()).Offset, Text: buf.Bytes()})
+			continue
+		}
+
+		formattedSpans, err := scanRegions(buf.Bytes())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, formatted := range formattedSpans {
+			if f.regions[formatted.Name] == nil {
+				continue
+			}
+			for _, span := range spans {
+				if span.Name == formatted.Name {
+					edits = append(edits, textEdit{Start: span.Start, End: span.End, Text: buf.Bytes()[formatted.Start:formatted.End]})
+				}
+			}
+		}
+	}
+
+	return applyEdits(data, edits), nil
+}
+
+func (f *RegionFile) WriteFile() (int, error) {
+	data, err := f.Render()
+	if err != nil {
+		return -1, err
+	}
+
+	if _, err := writeFileIfChanged(f.outputFS(), f.filename, data); err != nil {
+		return -1, err
+	}
+
+	return len(data), nil
+}
+
+func (f *RegionFile) regionEdits() ([]textEdit, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	spans, err := scanRegions(f.src)
+	if err != nil {
+		return nil, err
+	}
+
+	edits := make([]textEdit, 0)
+	found := map[string]bool{}
+
+	for _, span := range spans {
+		found[span.Name] = true
+		if r, ok := f.regions[span.Name]; ok {
+			edits = append(edits, textEdit{Start: span.Start, End: span.End, Text: r.render(span.Indent)})
+		}
+	}
+
+	for _, name := range f.order {
+		if !found[name] {
+			return nil, fmt.Errorf("region `%s` not found", name)
+		}
+	}
+
+	return edits, nil
+}
+
+// regionSpan is the byte range between the lines of the begin and end markers of a region
+type regionSpan struct {
+	Name   string
+	Indent string
+	Start  int
+	End    int
+}
+
+func scanRegions(src []byte) ([]regionSpan, error) {
+	spans := make([]regionSpan, 0)
+	found := map[string]bool{}
+
+	name, indent, start := "", "", -1
+
+	for offset, line := 0, 0; offset < len(src); line++ {
+		end := bytes.IndexByte(src[offset:], '\n')
+		if end < 0 {
+			end = len(src)
+		} else {
+			end += offset + 1
+		}
+
+		text := strings.TrimRight(string(src[offset:end]), "\r\n")
+		trimmed := strings.TrimLeft(text, " \t")
+
+		switch {
+		case strings.HasPrefix(trimmed, regionBeginMarker):
+			if start >= 0 {
+				return nil, fmt.Errorf("line %d: region `%s` is not closed before the next %s", line+1, name, strings.TrimSpace(regionBeginMarker))
+			}
+			name = strings.TrimSpace(strings.TrimPrefix(trimmed, regionBeginMarker))
+			if name == "" {
+				return nil, fmt.Errorf("line %d: missing region name", line+1)
+			}
+			if found[name] {
+				return nil, fmt.Errorf("line %d: duplicated region `%s`", line+1, name)
+			}
+			found[name] = true
+			indent, start = text[:len(text)-len(trimmed)], end
+		case trimmed == regionEndMarker:
+			if start < 0 {
+				return nil, fmt.Errorf("line %d: unexpected %s", line+1, regionEndMarker)
+			}
+			spans = append(spans, regionSpan{Name: name, Indent: indent, Start: start, End: offset})
+			start = -1
+		}
+
+		offset = end
+	}
+
+	if start >= 0 {
+		return nil, fmt.Errorf("region `%s` is not closed", name)
+	}
+
+	return spans, nil
+}
+
+func (f *RegionFile) importEdits(specs []*SnippetImport) ([]textEdit, error) {
+	fset := token.NewFileSet()
+
+	astFile, err := parser.ParseFile(fset, f.filename, f.src, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	offsetOf := func(pos token.Pos) int {
+		return fset.Position(pos).Offset
+	}
+
+	var block *ast.GenDecl
+	var last ast.Node = astFile.Name
+
+	for _, decl := range astFile.Decls {
+		if d, ok := decl.(*ast.GenDecl); ok && d.Tok == token.IMPORT {
+			last = d
+			if d.Lparen.IsValid() {
+				block = d
+			}
+		}
+	}
+
+	groupOf := func(spec *SnippetImport) int {
+		return formatx.ImportGroup(f.filename, spec.Alias, spec.Path)
+	}
+
+	lines := func(specs []*SnippetImport) []byte {
+		buf := &bytes.Buffer{}
+		for i, spec := range specs {
+			if i > 0 && groupOf(specs[i-1]) != groupOf(spec) {
+				buf.WriteString("\n")
+			}
+			buf.WriteString("\t")
+			buf.Write(spec.Bytes())
+			buf.WriteString("\n")
+		}
+		return buf.Bytes()
+	}
+
+	sort.SliceStable(specs, func(i, j int) bool {
+		return groupOf(specs[i]) < groupOf(specs[j])
+	})
+
+	if block == nil {
+		end := offsetOf(last.End())
+		return []textEdit{{Start: end, End: end, Text: []byte("\n\nimport (\n" + string(lines(specs)) + ")")}}, nil
+	}
+
+	groups := importGroupsOf(fset, block, func(s *ast.ImportSpec) int {
+		name := ""
+		if s.Name != nil {
+			name = s.Name.Name
+		}
+		importPath, _ := strconv.Unquote(s.Path.Value)
+		return formatx.ImportGroup(f.filename, name, importPath)
+	})
+
+	edits := make([]textEdit, 0, len(specs))
+	added := map[int][]*SnippetImport{}
+	addedOrder := make([]int, 0)
+
+	for _, spec := range specs {
+		g := groupOf(spec)
+
+		group := groups.find(g)
+		if group == nil {
+			if _, ok := added[g]; !ok {
+				addedOrder = append(addedOrder, g)
+			}
+			added[g] = append(added[g], spec)
+			continue
+		}
+
+		// after the last spec of the group unless one sorts higher
+		insertAt := lineEnd(f.src, offsetOf(group.specs[len(group.specs)-1].End()))
+
+		for _, importSpec := range group.specs {
+			importPath, _ := strconv.Unquote(importSpec.Path.Value)
+			if importPath > spec.Path {
+				var start ast.Node = importSpec
+				if importSpec.Doc != nil {
+					start = importSpec.Doc
+				}
+				insertAt = lineStart(f.src, offsetOf(start.Pos()))
+				break
+			}
+		}
+
+		edits = append(edits, textEdit{Start: insertAt, End: insertAt, Text: []byte("\t" + string(spec.Bytes()) + "\n")})
+	}
+
+	// specs without a matching group form new groups, placed before the first group ordered after them
+	for _, g := range addedOrder {
+		text := lines(added[g])
+
+		if next := groups.after(g); next != nil {
+			edits = append(edits, textEdit{Start: next.start, End: next.start, Text: append(text, '\n')})
+			continue
+		}
+
+		insertAt := lineStart(f.src, offsetOf(block.Rparen))
+		if len(groups) > 0 {
+			text = append([]byte("\n"), text...)
+		}
+		edits = append(edits, textEdit{Start: insertAt, End: insertAt, Text: text})
+	}
+
+	return edits, nil
+}
+
+type importGroup struct {
+	specs  []*ast.ImportSpec
+	groups map[int]bool
+	start  int
+}
+
+type importGroups []*importGroup
+
+// importGroupsOf splits the specs of the import block by blank lines, as gofmt does
+func importGroupsOf(fset *token.FileSet, block *ast.GenDecl, groupOf func(s *ast.ImportSpec) int) importGroups {
+	groups := importGroups{}
+
+	lastLine := 0
+
+	for _, s := range block.Specs {
+		importSpec := s.(*ast.ImportSpec)
+
+		var start ast.Node = importSpec
+		if importSpec.Doc != nil {
+			start = importSpec.Doc
+		}
+
+		if len(groups) == 0 || fset.Position(start.Pos()).Line > lastLine+1 {
+			pos := fset.Position(start.Pos())
+			groups = append(groups, &importGroup{groups: map[int]bool{}, start: pos.Offset - pos.Column + 1})
+		}
+
+		group := groups[len(groups)-1]
+		group.specs = append(group.specs, importSpec)
+		group.groups[groupOf(importSpec)] = true
+
+		lastLine = fset.Position(importSpec.End()).Line
+		if importSpec.Comment != nil {
+			lastLine = fset.Position(importSpec.Comment.End()).Line
+		}
+	}
+
+	return groups
+}
+
+// find prefers a group holding only imports of g over a mixed one
+func (groups importGroups) find(g int) *importGroup {
+	var mixed *importGroup
+	for _, group := range groups {
+		if group.groups[g] {
+			if len(group.groups) == 1 {
+				return group
+			}
+			if mixed == nil {
+				mixed = group
+			}
+		}
+	}
+	return mixed
+}
+
+func (groups importGroups) after(g int) *importGroup {
+	for _, group := range groups {
+		for other := range group.groups {
+			if other > g {
+				return group
+			}
+		}
+	}
+	return nil
+}
+
+type Region struct {
+	Name string
+	mu   sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (r *Region) WriteBlock(ss ...Snippet) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, s := range ss {
+		if i > 0 || r.buf.Len() > 0 {
+			r.buf.WriteString("\n\n")
+		}
+		r.buf.Write(s.Bytes())
+	}
+}
+
+func (r *Region) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.buf.Write(p)
+}
+
+func (r *Region) render(indent string) []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	src := bytes.TrimSpace(r.buf.Bytes())
+	if len(src) == 0 {
+		return nil
+	}
+
+	if formatted, err := format.Source(append([]byte(indent), src...)); err == nil {
+		src = bytes.TrimRight(formatted, "\n")
+	} else {
+		lines := bytes.Split(src, []byte("\n"))
+		for i := range lines {
+			if len(lines[i]) > 0 {
+				lines[i] = append([]byte(indent), lines[i]...)
+			}
+		}
+		src = bytes.Join(lines, []byte("\n"))
+	}
+
+	return append(src, '\n')
+}
+
+type textEdit struct {
+	Start int
+	End   int
+	Text  []byte
+}
+
+func applyEdits(src []byte, edits []textEdit) []byte {
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].Start < edits[j].Start
+	})
+
+	buf := &bytes.Buffer{}
+	offset := 0
+
+	for _, edit := range edits {
+		buf.Write(src[offset:edit.Start])
+		buf.Write(edit.Text)
+		offset = edit.End
+	}
+	buf.Write(src[offset:])
+
+	return buf.Bytes()
+}
+
+func lineStart(src []byte, offset int) int {
+	return bytes.LastIndexByte(src[:offset], '\n') + 1
+}
+
+func lineEnd(src []byte, offset int) int {
+	if i := bytes.IndexByte(src[offset:], '\n'); i >= 0 {
+		return offset + i + 1
+	}
+	return len(src)
+}
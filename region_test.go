@@ -0,0 +1,300 @@
+package codegen
+
+import (
+	"go/format"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegionFile(t *testing.T) {
+	tt := require.New(t)
+
+	src := `package pkg
+
+import (
+	"fmt"
+	// for uuid
+	xuuid "github.com/google/uuid"
+	"os"
+)
+
+// User is written by hand.
+type User struct {
+	ID   xuuid.UUID
+	// codegen:begin fields
+	Old string
+	// codegen:end
+}
+
+// codegen:begin consts
+const Old = 1
+// codegen:end
+
+// codegen:begin untouched
+var   keep = 1
+// codegen:end
+
+func   (u User) String() string {
+	return fmt.Sprint(u.ID, os.Args)
+}
+`
+
+	fs := NewMemFS()
+	tt.NoError(fs.WriteFile("pkg/user.go", []byte(src)))
+
+	f := NewRegionFile("pkg/user.go").WithFS(fs).WithResolver(StaticPackageResolver{
+		"fmt":                      "fmt",
+		"os":                       "os",
+		"time":                     "time",
+		"strings":                  "strings",
+		"github.com/google/uuid":   "uuid",
+		"github.com/go-courier/x2": "x",
+	})
+
+	f.Region("fields").WriteBlock(
+		Var(f.TypeOf(reflect.TypeOf(time.Time{})), "CreatedAt"),
+		Var(Type(f.Use("github.com/google/uuid", "UUID")), "OwnerID"),
+	)
+	f.Region("consts").WriteBlock(
+		DeclConst(Assign(Id("A")).By(f.Expr("?(?)", Id(f.Use("strings", "ToUpper")), "a"))),
+		DeclConst(Assign(Id("B")).By(Id(f.Use("github.com/go-courier/x2", "B")))),
+	)
+
+	_, err := f.WriteFile()
+	tt.NoError(err)
+
+	data, err := fs.ReadFile("pkg/user.go")
+	tt.NoError(err)
+
+	tt.Equal(`package pkg
+
+import (
+	"fmt"
+	"github.com/go-courier/x2"
+	// for uuid
+	xuuid "github.com/google/uuid"
+	"os"
+	"strings"
+	"time"
+)
+
+// User is written by hand.
+type User struct {
+	ID   xuuid.UUID
+	// codegen:begin fields
+	CreatedAt time.Time
+
+	OwnerID xuuid.UUID
+	// codegen:end
+}
+
+// codegen:begin consts
+const A = strings.ToUpper("a")
+
+const B = x.B
+// codegen:end
+
+// codegen:begin untouched
+var   keep = 1
+// codegen:end
+
+func   (u User) String() string {
+	return fmt.Sprint(u.ID, os.Args)
+}
+`, string(data))
+}
+
+func TestRegionFile_WithoutImportBlock(t *testing.T) {
+	tt := require.New(t)
+
+	fs := NewMemFS()
+	tt.NoError(fs.WriteFile("pkg/a.go", []byte("package pkg\n\nfunc Do() {\n\t// codegen:begin body\n\t// codegen:end\n}\n")))
+
+	f := NewRegionFile("pkg/a.go").WithFS(fs).WithResolver(StaticPackageResolver{"fmt": "fmt"})
+	f.Region("body").WriteBlock(Call(f.Use("fmt", "Println"), Val("hi")))
+
+	data, err := f.Render()
+	tt.NoError(err)
+	tt.Equal("package pkg\n\nimport (\n\t\"fmt\"\n)\n\nfunc Do() {\n\t// codegen:begin body\n\tfmt.Println(\"hi\")\n\t// codegen:end\n}\n", string(data))
+}
+
+func TestRegionFile_Errors(t *testing.T) {
+	render := func(src string, region string) error {
+		fs := NewMemFS()
+		_ = fs.WriteFile("a.go", []byte(src))
+
+		f := NewRegionFile("a.go").WithFS(fs).WithResolver(StaticPackageResolver{})
+		f.Region(region).WriteBlock(Id("x"))
+		_, err := f.Render()
+		return err
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		f := NewRegionFile("a.go").WithFS(NewMemFS())
+		_, err := f.Render()
+		require.Error(t, err)
+	})
+
+	t.Run("region not found", func(t *testing.T) {
+		require.EqualError(t, render("package a\n", "a"), "a.go: region `a` not found")
+	})
+
+	t.Run("region not closed", func(t *testing.T) {
+		require.EqualError(t, render("package a\n// codegen:begin a\n", "a"), "a.go: region `a` is not closed")
+	})
+
+	t.Run("unexpected end", func(t *testing.T) {
+		require.EqualError(t, render("package a\n// codegen:end\n", "a"), "a.go: line 2: unexpected // codegen:end")
+	})
+
+	t.Run("invalid result", func(t *testing.T) {
+		require.Error(t, render("package a\n// codegen:begin a\n// codegen:end\n", "a"))
+	})
+}
+
+func TestRegionFile_GofmtStable(t *testing.T) {
+	tt := require.New(t)
+
+	src := `package pkg
+
+import (
+	"fmt"
+)
+
+type User struct {
+	ID   int
+	Name string
+	// codegen:begin fields
+	// codegen:end
+}
+
+func (u User) String() string {
+	return fmt.Sprint(u.ID)
+}
+`
+
+	fs := NewMemFS()
+	tt.NoError(fs.WriteFile("pkg/user.go", []byte(src)))
+
+	f := NewRegionFile("pkg/user.go").WithFS(fs).WithResolver(StaticPackageResolver{})
+	f.Region("fields").WriteBlock(
+		Var(f.TypeOf(reflect.TypeOf(time.Time{})), "CreatedAt"),
+	)
+
+	data, err := f.Render()
+	tt.NoError(err)
+
+	formatted, err := format.Source(data)
+	tt.NoError(err)
+	tt.Equal(string(formatted), string(data))
+}
+
+func TestRegionFile_ImportGroups(t *testing.T) {
+	tt := require.New(t)
+
+	src := `package pkg
+
+import (
+	"os"
+
+	"github.com/x/y"
+)
+
+var _ = os.Args
+var _ = y.Y
+
+// codegen:begin vars
+// codegen:end
+`
+
+	fs := NewMemFS()
+	tt.NoError(fs.WriteFile("pkg/a.go", []byte(src)))
+
+	f := NewRegionFile("pkg/a.go").WithFS(fs).WithResolver(StaticPackageResolver{
+		"os":                              "os",
+		"strings":                         "strings",
+		"github.com/x/y":                  "y",
+		"github.com/a/b":                  "b",
+		"github.com/go-courier/codegen/x": "x",
+	})
+	f.Region("vars").WriteBlock(
+		DeclVar(Assign(Id("_")).By(Id(f.Use("github.com/a/b", "B")))),
+		DeclVar(Assign(Id("_")).By(Id(f.Use("strings", "ToUpper")))),
+		DeclVar(Assign(Id("_")).By(Id(f.Use("github.com/go-courier/codegen/x", "X")))),
+	)
+
+	data, err := f.Render()
+	tt.NoError(err)
+	tt.Equal(`package pkg
+
+import (
+	"os"
+	"strings"
+
+	"github.com/a/b"
+	"github.com/x/y"
+
+	"github.com/go-courier/codegen/x"
+)
+
+var _ = os.Args
+var _ = y.Y
+
+// codegen:begin vars
+var _ = b.B
+
+var _ = strings.ToUpper
+
+var _ = x.X
+// codegen:end
+`, string(data))
+
+	tt.NoError(fs.WriteFile("pkg/b.go", []byte("package pkg\n\nimport (\n\t\"github.com/x/y\"\n)\n\nvar _ = y.Y\n\n// codegen:begin vars\n// codegen:end\n")))
+
+	f = NewRegionFile("pkg/b.go").WithFS(fs).WithResolver(StaticPackageResolver{"github.com/x/y": "y", "strings": "strings"})
+	f.Region("vars").WriteBlock(DeclVar(Assign(Id("_")).By(Id(f.Use("strings", "ToUpper")))))
+
+	data, err = f.Render()
+	tt.NoError(err)
+	tt.Equal("package pkg\n\nimport (\n\t\"strings\"\n\n\t\"github.com/x/y\"\n)\n\nvar _ = y.Y\n\n// codegen:begin vars\nvar _ = strings.ToUpper\n// codegen:end\n", string(data))
+}
+
+func TestRegionFile_KeepsHandWrittenCode(t *testing.T) {
+	tt := require.New(t)
+
+	src := `package pkg
+
+func Values() map[string]int {
+	values := map[string]int{"a":1,
+		"b":   2}
+	// codegen:begin values
+	// codegen:end
+	return   values
+}
+`
+
+	fs := NewMemFS()
+	tt.NoError(fs.WriteFile("pkg/a.go", []byte(src)))
+
+	f := NewRegionFile("pkg/a.go").WithFS(fs).WithResolver(StaticPackageResolver{})
+	f.Region("values").WriteBlock(
+		f.Expr("values[?] =   ?", "c", 3),
+	)
+
+	data, err := f.Render()
+	tt.NoError(err)
+	tt.Equal(`package pkg
+
+func Values() map[string]int {
+	values := map[string]int{"a":1,
+		"b":   2}
+	// codegen:begin values
+	values["c"] = 3
+	// codegen:end
+	return   values
+}
+`, string(data))
+}
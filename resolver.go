@@ -0,0 +1,143 @@
+package codegen
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-courier/codegen/formatx"
+	"golang.org/x/tools/go/packages"
+)
+
+type Package struct {
+	PkgPath string
+	Name    string
+}
+
+type PackageResolver interface {
+	Resolve(importPaths ...string) ([]*Package, error)
+}
+
+var DefaultPackageResolver PackageResolver = NewCachedPackageResolver("")
+
+func NewCachedPackageResolver(dir string) *CachedPackageResolver {
+	return &CachedPackageResolver{
+		dir:    dir,
+		pkgs:   map[string]*Package{},
+		failed: map[string]error{},
+	}
+}
+
+// CachedPackageResolver loads all missing import paths of one Resolve call with a single packages.Load,
+// and caches failed lookups too, so a bad import path does not shell out to the go tool again.
+type CachedPackageResolver struct {
+	dir    string
+	rw     sync.RWMutex
+	pkgs   map[string]*Package
+	failed map[string]error
+}
+
+func (r *CachedPackageResolver) Resolve(importPaths ...string) ([]*Package, error) {
+	missing := make([]string, 0)
+
+	r.rw.RLock()
+	for _, importPath := range importPaths {
+		if _, ok := r.pkgs[importPath]; ok {
+			continue
+		}
+		if _, ok := r.failed[importPath]; ok {
+			continue
+		}
+		missing = append(missing, importPath)
+	}
+	r.rw.RUnlock()
+
+	if len(missing) > 0 {
+		if err := r.load(missing...); err != nil {
+			return nil, err
+		}
+	}
+
+	r.rw.RLock()
+	defer r.rw.RUnlock()
+
+	var errs []string
+
+	resolved := make([]*Package, len(importPaths))
+	for i, importPath := range importPaths {
+		if err, ok := r.failed[importPath]; ok {
+			errs = append(errs, fmt.Sprintf("resolve `%s` failed: %s", importPath, err))
+			continue
+		}
+		resolved[i] = r.pkgs[importPath]
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	return resolved, nil
+}
+
+func (r *CachedPackageResolver) Prefetch(importPaths ...string) error {
+	_, err := r.Resolve(importPaths...)
+	return err
+}
+
+func (r *CachedPackageResolver) load(importPaths ...string) error {
+	pkgs, err := packages.Load(&packages.Config{Mode: packages.NeedName, Dir: r.dir}, importPaths...)
+	if err != nil {
+		return err
+	}
+
+	loaded := map[string]*Package{}
+	failed := map[string]error{}
+
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) > 0 {
+			failed[pkg.ID] = pkg.Errors[0]
+			continue
+		}
+		if pkg.Name == "" {
+			continue
+		}
+		p := &Package{PkgPath: pkg.PkgPath, Name: pkg.Name}
+		loaded[pkg.ID] = p
+		loaded[pkg.PkgPath] = p
+		loaded[deVendor(pkg.PkgPath)] = p
+	}
+
+	r.rw.Lock()
+	defer r.rw.Unlock()
+
+	for _, importPath := range importPaths {
+		if p, ok := loaded[importPath]; ok {
+			r.pkgs[importPath] = p
+			continue
+		}
+		if err, ok := failed[importPath]; ok {
+			r.failed[importPath] = err
+			continue
+		}
+		r.failed[importPath] = errors.New("package not found")
+	}
+
+	return nil
+}
+
+type StaticPackageResolver map[string]string
+
+func (r StaticPackageResolver) Resolve(importPaths ...string) ([]*Package, error) {
+	resolved := make([]*Package, len(importPaths))
+
+	for i, importPath := range importPaths {
+		name := r[importPath]
+		if name == "" {
+			name = formatx.GuessPkgName(importPath)
+		}
+		resolved[i] = &Package{PkgPath: importPath, Name: name}
+	}
+
+	return resolved, nil
+}
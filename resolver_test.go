@@ -0,0 +1,76 @@
+package codegen
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachedPackageResolver(t *testing.T) {
+	tt := require.New(t)
+
+	r := NewCachedPackageResolver("")
+
+	tt.NoError(r.Prefetch("math/rand", "github.com/go-courier/codegen/formatx"))
+	tt.Len(r.pkgs, 2)
+
+	pkgs, err := r.Resolve("math/rand", "github.com/go-courier/codegen/formatx")
+	tt.NoError(err)
+	tt.Equal([]*Package{
+		{PkgPath: "math/rand", Name: "rand"},
+		{PkgPath: "github.com/go-courier/codegen/formatx", Name: "formatx"},
+	}, pkgs)
+
+	cached, err := r.Resolve("math/rand")
+	tt.NoError(err)
+	tt.True(pkgs[0] == cached[0])
+
+	_, err = r.Resolve("math/rand", "github.com/go-courier/codegen/not-exists")
+	tt.Error(err)
+	tt.Contains(err.Error(), "github.com/go-courier/codegen/not-exists")
+	tt.Len(r.pkgs, 2)
+	tt.Len(r.failed, 1)
+
+	r.failed["github.com/go-courier/codegen/not-exists"] = errors.New("cached")
+	_, err = r.Resolve("github.com/go-courier/codegen/not-exists")
+	tt.EqualError(err, "resolve `github.com/go-courier/codegen/not-exists` failed: cached")
+}
+
+func TestStaticPackageResolver(t *testing.T) {
+	tt := require.New(t)
+
+	pkgs, err := StaticPackageResolver{"github.com/lib/pq": "pgdriver"}.Resolve("github.com/lib/pq", "github.com/davecgh/go-spew/spew")
+	tt.NoError(err)
+	tt.Equal([]*Package{
+		{PkgPath: "github.com/lib/pq", Name: "pgdriver"},
+		{PkgPath: "github.com/davecgh/go-spew/spew", Name: "spew"},
+	}, pkgs)
+}
+
+type countingResolver struct {
+	calls [][]string
+}
+
+func (r *countingResolver) Resolve(importPaths ...string) ([]*Package, error) {
+	r.calls = append(r.calls, importPaths)
+	return StaticPackageResolver{}.Resolve(importPaths...)
+}
+
+func TestFile_Prefetch(t *testing.T) {
+	tt := require.New(t)
+
+	counting := &countingResolver{}
+	r := NewCachedPackageResolver("")
+
+	file := NewFile("main", "main.go").WithResolver(r)
+	tt.NoError(file.Prefetch("fmt", "strings"))
+	tt.Len(r.pkgs, 2)
+
+	tt.Equal("fmt.Println", file.Use("fmt", "Println"))
+	tt.Equal("strings.ToUpper", file.Use("strings", "ToUpper"))
+
+	p := NewProject("gen").WithResolver(counting)
+	tt.NoError(p.Prefetch("fmt", "strings"))
+	tt.Equal([][]string{{"fmt", "strings"}}, counting.calls)
+}
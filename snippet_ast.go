@@ -0,0 +1,627 @@
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+func ParseSnippets(src string) ([]Snippet, error) {
+	fset := token.NewFileSet()
+
+	if f, err := parser.ParseFile(fset, "", src, parser.ParseComments); err == nil {
+		c := newSnippetConverter(fset, f.Comments)
+		snippets := c.declSnippets(f.Name.End(), f.FileEnd, f.Decls)
+		return snippets, c.err
+	}
+
+	if f, err := parser.ParseFile(fset, "", "package p\n"+src, parser.ParseComments); err == nil {
+		c := newSnippetConverter(fset, f.Comments)
+		snippets := c.declSnippets(f.Name.End(), f.FileEnd, f.Decls)
+		return snippets, c.err
+	}
+
+	f, err := parser.ParseFile(fset, "", "package p\nfunc _() {\n"+src+"\n}", parser.ParseComments)
+	if err != nil {
+		if expr, exprErr := parser.ParseExprFrom(fset, "", src, parser.ParseComments); exprErr == nil {
+			c := newSnippetConverter(fset, nil)
+			snippet := c.exprSnippet(expr)
+			return []Snippet{snippet}, c.err
+		}
+		return nil, err
+	}
+
+	c := newSnippetConverter(fset, f.Comments)
+	snippets := c.blockSnippets(f.Decls[0].(*ast.FuncDecl).Body)
+	return snippets, c.err
+}
+
+func SnippetOf(node ast.Node) (Snippet, error) {
+	c := newSnippetConverter(token.NewFileSet(), nil)
+
+	var snippet Snippet
+	switch n := node.(type) {
+	case ast.Decl:
+		snippet = c.declSnippet(n)
+	case ast.Stmt:
+		snippet = c.stmtSnippet(n)
+	case ast.Expr:
+		snippet = c.exprSnippet(n)
+	case *ast.Field:
+		snippet = c.field(n)
+	case *ast.File:
+		snippet = Body(c.declSnippets(n.Name.End(), n.FileEnd, n.Decls))
+	default:
+		snippet = c.rawSnippet(node)
+	}
+	return snippet, c.err
+}
+
+func SnippetTypeOf(expr ast.Expr) (SnippetType, error) {
+	c := newSnippetConverter(token.NewFileSet(), nil)
+	tpe := c.snippetTypeOf(expr)
+	return tpe, c.err
+}
+
+// snippetConverter keeps the comments of the parsed source, so those without a place
+// in the snippets are printed as comment lines or kept by printing the node as is.
+type snippetConverter struct {
+	fset     *token.FileSet
+	comments []*ast.CommentGroup
+	used     map[*ast.CommentGroup]bool
+	err      error
+}
+
+func newSnippetConverter(fset *token.FileSet, comments []*ast.CommentGroup) *snippetConverter {
+	return &snippetConverter{
+		fset:     fset,
+		comments: comments,
+		used:     map[*ast.CommentGroup]bool{},
+	}
+}
+
+func (c *snippetConverter) snippetTypeOf(expr ast.Expr) SnippetType {
+	switch x := expr.(type) {
+	case *ast.Ident:
+		if isBuiltInType(x.Name) {
+			return BuiltInType(x.Name)
+		}
+		return Type(x.Name)
+	case *ast.SelectorExpr:
+		if pkg, ok := x.X.(*ast.Ident); ok {
+			return Type(pkg.Name + "." + x.Sel.Name)
+		}
+	case *ast.StarExpr:
+		return Star(c.snippetTypeOf(x.X))
+	case *ast.Ellipsis:
+		return Ellipsis(c.snippetTypeOf(x.Elt))
+	case *ast.ArrayType:
+		if x.Len == nil {
+			return Slice(c.snippetTypeOf(x.Elt))
+		}
+		if lit, ok := x.Len.(*ast.BasicLit); ok && lit.Kind == token.INT {
+			if n, err := strconv.Atoi(lit.Value); err == nil {
+				return Array(c.snippetTypeOf(x.Elt), n)
+			}
+		}
+	case *ast.MapType:
+		return Map(c.snippetTypeOf(x.Key), c.snippetTypeOf(x.Value))
+	case *ast.ChanType:
+		if x.Dir == ast.SEND|ast.RECV {
+			return Chan(c.snippetTypeOf(x.Value))
+		}
+	case *ast.FuncType:
+		return c.funcType(x)
+	case *ast.StructType:
+		return Struct(c.fields(x.Fields)...)
+	case *ast.InterfaceType:
+		return c.interfaceType(x)
+	case *ast.IndexExpr:
+		return Instance(c.snippetTypeOf(x.X), c.snippetTypeOf(x.Index))
+	case *ast.IndexListExpr:
+		typeArgs := make([]SnippetType, len(x.Indices))
+		for i := range x.Indices {
+			typeArgs[i] = c.snippetTypeOf(x.Indices[i])
+		}
+		return Instance(c.snippetTypeOf(x.X), typeArgs...)
+	case *ast.UnaryExpr:
+		if x.Op == token.TILDE {
+			return Tilde(c.snippetTypeOf(x.X))
+		}
+	case *ast.BinaryExpr:
+		if x.Op == token.OR {
+			return Union(c.unionTerms(x)...)
+		}
+	}
+	return BuiltInType(c.rawSource(expr))
+}
+
+func (c *snippetConverter) declSnippets(from token.Pos, to token.Pos, decls []ast.Decl) []Snippet {
+	snippets := make([]Snippet, 0, len(decls))
+	for _, decl := range decls {
+		s := c.declSnippet(decl)
+		if c.hasUnusedComments(decl.Pos(), decl.End()) {
+			s = c.rawSnippet(decl)
+		}
+		snippets = append(snippets, c.commentsBetween(from, decl.Pos())...)
+		snippets = append(snippets, s)
+		from = decl.End()
+	}
+	return append(snippets, c.commentsBetween(from, to)...)
+}
+
+func (c *snippetConverter) declSnippet(decl ast.Decl) Snippet {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		f := c.funcType(d.Type).Named(d.Name.Name)
+		if d.Type.TypeParams != nil {
+			f = f.WithTypeParams(c.fields(d.Type.TypeParams)...)
+		}
+		if d.Recv != nil && len(d.Recv.List) == 1 {
+			f = f.MethodOf(c.field(d.Recv.List[0]))
+		}
+		if d.Body != nil {
+			f = f.Do(c.blockSnippets(d.Body)...)
+		}
+		if d.Doc != nil {
+			f = f.WithComments(c.commentLines(d.Doc)...)
+		}
+		return f
+	case *ast.GenDecl:
+		specs := make([]SnippetSpec, 0, len(d.Specs))
+
+		for _, s := range d.Specs {
+			spec, ok := c.specSnippet(s)
+			if !ok {
+				return c.rawSnippet(d)
+			}
+			specs = append(specs, spec)
+		}
+
+		decl := &SnippetTypeDecl{Token: d.Tok, Specs: specs}
+		if d.Doc != nil {
+			decl = decl.WithComments(c.commentLines(d.Doc)...)
+		}
+		return decl
+	}
+	return c.rawSnippet(decl)
+}
+
+func (c *snippetConverter) specSnippet(spec ast.Spec) (SnippetSpec, bool) {
+	switch s := spec.(type) {
+	case *ast.ImportSpec:
+		importPath, _ := strconv.Unquote(s.Path.Value)
+		i := Import(importPath)
+		if s.Name != nil {
+			i = i.As(s.Name.Name)
+		}
+		if s.Doc != nil {
+			i = i.WithComments(c.commentLines(s.Doc)...)
+		}
+		if s.Comment != nil {
+			i = i.WithLineComment(strings.Join(c.commentLines(s.Comment), " "))
+		}
+		return i, true
+	case *ast.TypeSpec:
+		f := &SnippetField{Type: c.snippetTypeOf(s.Type), Names: idents(s.Name)}
+		if s.TypeParams != nil {
+			f = f.WithTypeParams(c.fields(s.TypeParams)...)
+		}
+		if s.Assign.IsValid() {
+			f = f.AsAlias()
+		}
+		if s.Doc != nil {
+			f = f.WithComments(c.commentLines(s.Doc)...)
+		}
+		return f, true
+	case *ast.ValueSpec:
+		if s.Doc != nil && s.Type == nil && len(s.Values) > 0 {
+			return nil, false
+		}
+
+		if s.Type != nil {
+			f := &SnippetField{Type: c.snippetTypeOf(s.Type), Names: idents(s.Names...)}
+			if s.Doc != nil {
+				f = f.WithComments(c.commentLines(s.Doc)...)
+			}
+			if len(s.Values) == 0 {
+				return f, true
+			}
+			return Assign(f).By(c.exprSnippets(s.Values)...), true
+		}
+
+		lhs := make([]SnippetCanAddr, len(s.Names))
+		for i, name := range idents(s.Names...) {
+			lhs[i] = name
+		}
+		return Assign(lhs...).By(c.exprSnippets(s.Values)...), true
+	}
+	return nil, false
+}
+
+func (c *snippetConverter) blockSnippets(block *ast.BlockStmt) []Snippet {
+	return c.stmtSnippets(block.Lbrace, block.Rbrace, block.List)
+}
+
+func (c *snippetConverter) stmtSnippets(from token.Pos, to token.Pos, stmts []ast.Stmt) []Snippet {
+	snippets := make([]Snippet, 0, len(stmts))
+	for _, stmt := range stmts {
+		s := c.stmtSnippet(stmt)
+		if c.hasUnusedComments(stmt.Pos(), stmt.End()) {
+			s = c.rawSnippet(stmt)
+		}
+		snippets = append(snippets, c.commentsBetween(from, stmt.Pos())...)
+		snippets = append(snippets, s)
+		from = stmt.End()
+	}
+	return append(snippets, c.commentsBetween(from, to)...)
+}
+
+func (c *snippetConverter) stmtSnippet(stmt ast.Stmt) Snippet {
+	switch s := stmt.(type) {
+	case *ast.ExprStmt:
+		return c.exprSnippet(s.X)
+	case *ast.DeclStmt:
+		return c.declSnippet(s.Decl)
+	case *ast.BlockStmt:
+		return Block(c.blockSnippets(s)...)
+	case *ast.ReturnStmt:
+		return Return(c.exprSnippets(s.Results)...)
+	case *ast.AssignStmt:
+		lhs := make([]SnippetCanAddr, len(s.Lhs))
+		for i := range s.Lhs {
+			addr, ok := c.exprSnippet(s.Lhs[i]).(SnippetCanAddr)
+			if !ok {
+				return c.rawSnippet(s)
+			}
+			lhs[i] = addr
+		}
+		return AssignWith(s.Tok, lhs...).By(c.exprSnippets(s.Rhs)...)
+	case *ast.IfStmt:
+		return c.ifStmt(s)
+	case *ast.ForStmt:
+		return For(c.optionalStmt(s.Init), c.optionalExpr(s.Cond), c.optionalStmt(s.Post)).Do(c.blockSnippets(s.Body)...)
+	case *ast.RangeStmt:
+		key, value := "", ""
+		if s.Key != nil {
+			ident, ok := s.Key.(*ast.Ident)
+			if !ok || s.Tok != token.DEFINE {
+				return c.rawSnippet(s)
+			}
+			key = ident.Name
+		}
+		if s.Value != nil {
+			ident, ok := s.Value.(*ast.Ident)
+			if !ok {
+				return c.rawSnippet(s)
+			}
+			value = ident.Name
+		}
+		stmt := ForRange(c.exprSnippet(s.X)).Do(c.blockSnippets(s.Body)...)
+		if key != "" {
+			stmt.Key = idents(ast.NewIdent(key))[0]
+		}
+		if value != "" {
+			stmt.Value = idents(ast.NewIdent(value))[0]
+		}
+		return stmt
+	case *ast.SwitchStmt:
+		if s.Tag == nil && s.Init != nil {
+			return c.rawSnippet(s)
+		}
+		stmt := Switch(c.optionalExpr(s.Tag))
+		if s.Init != nil {
+			stmt = stmt.InitWith(c.stmtSnippet(s.Init))
+		}
+		return stmt.When(c.clauses(s.Body)...)
+	case *ast.SelectStmt:
+		return Select(c.clauses(s.Body)...)
+	case *ast.GoStmt:
+		return c.callExpr(s.Call).AsGo()
+	case *ast.DeferStmt:
+		return c.callExpr(s.Call).AsDefer()
+	case *ast.BranchStmt:
+		if s.Label == nil {
+			switch s.Tok {
+			case token.BREAK:
+				return Break
+			case token.CONTINUE:
+				return Continue
+			case token.FALLTHROUGH:
+				return Fallthrough
+			}
+		}
+	}
+	return c.rawSnippet(stmt)
+}
+
+func (c *snippetConverter) ifStmt(s *ast.IfStmt) *SnippetIfStmt {
+	stmt := &SnippetIfStmt{
+		Init: c.optionalStmt(s.Init),
+		Cond: c.exprSnippet(s.Cond),
+		Body: c.blockSnippets(s.Body),
+	}
+
+	switch e := s.Else.(type) {
+	case *ast.IfStmt:
+		stmt.ElseList = append(stmt.ElseList, c.ifStmt(e))
+	case *ast.BlockStmt:
+		stmt.ElseList = append(stmt.ElseList, &SnippetIfStmt{Body: c.blockSnippets(e)})
+	}
+
+	return stmt
+}
+
+func (c *snippetConverter) clauses(body *ast.BlockStmt) []*SnippetClause {
+	list := make([]*SnippetClause, 0, len(body.List))
+
+	for i, stmt := range body.List {
+		end := body.Rbrace
+		if i+1 < len(body.List) {
+			end = body.List[i+1].Pos()
+		}
+
+		switch x := stmt.(type) {
+		case *ast.CaseClause:
+			list = append(list, Clause(c.exprSnippets(x.List)...).Do(c.stmtSnippets(x.Colon, end, x.Body)...))
+		case *ast.CommClause:
+			if x.Comm == nil {
+				list = append(list, Clause().Do(c.stmtSnippets(x.Colon, end, x.Body)...))
+			} else {
+				list = append(list, Clause(c.stmtSnippet(x.Comm)).Do(c.stmtSnippets(x.Colon, end, x.Body)...))
+			}
+		}
+	}
+
+	return list
+}
+
+func (c *snippetConverter) optionalStmt(stmt ast.Stmt) Snippet {
+	if stmt == nil {
+		return nil
+	}
+	return c.stmtSnippet(stmt)
+}
+
+func (c *snippetConverter) optionalExpr(expr ast.Expr) Snippet {
+	if expr == nil {
+		return nil
+	}
+	return c.exprSnippet(expr)
+}
+
+func (c *snippetConverter) exprSnippets(exprs []ast.Expr) []Snippet {
+	snippets := make([]Snippet, 0, len(exprs))
+	for _, expr := range exprs {
+		snippets = append(snippets, c.exprSnippet(expr))
+	}
+	return snippets
+}
+
+func (c *snippetConverter) exprSnippet(expr ast.Expr) Snippet {
+	switch x := expr.(type) {
+	case *ast.Ident:
+		switch x.Name {
+		case "nil":
+			return Nil
+		case "true":
+			return True
+		case "false":
+			return False
+		case "iota":
+			return Iota
+		}
+		return idents(x)[0]
+	case *ast.BasicLit:
+		return Lit(x.Value)
+	case *ast.CompositeLit:
+		var tpe SnippetType
+		if x.Type != nil {
+			tpe = c.snippetTypeOf(x.Type)
+		}
+		return Compose(tpe, c.exprSnippets(x.Elts)...)
+	case *ast.KeyValueExpr:
+		return KeyValue(c.exprSnippet(x.Key), c.exprSnippet(x.Value))
+	case *ast.SelectorExpr:
+		return Sel(c.exprSnippet(x.X), idents(x.Sel)[0])
+	case *ast.StarExpr:
+		return Star(c.snippetTypeOf(x.X))
+	case *ast.UnaryExpr:
+		if x.Op == token.AND {
+			if addr, ok := c.exprSnippet(x.X).(SnippetCanAddr); ok {
+				return Unary(addr)
+			}
+		}
+	case *ast.ParenExpr:
+		return Paren(c.exprSnippet(x.X))
+	case *ast.CallExpr:
+		return c.callExpr(x)
+	case *ast.TypeAssertExpr:
+		if x.Type != nil {
+			return TypeAssert(c.snippetTypeOf(x.Type), c.exprSnippet(x.X))
+		}
+	case *ast.FuncLit:
+		return c.funcType(x.Type).Do(c.blockSnippets(x.Body)...)
+	case *ast.ArrayType, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.StructType, *ast.InterfaceType:
+		return c.snippetTypeOf(x)
+	}
+	return c.rawSnippet(expr)
+}
+
+func (c *snippetConverter) callExpr(call *ast.CallExpr) *SnippetCallExpr {
+	var fn Snippet
+	switch fun := call.Fun.(type) {
+	case *ast.ArrayType, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.StarExpr:
+		fn = Paren(c.snippetTypeOf(fun))
+		if _, ok := fun.(*ast.ArrayType); ok {
+			fn = c.snippetTypeOf(fun)
+		}
+	default:
+		fn = c.exprSnippet(fun)
+	}
+
+	expr := CallWith(fn, c.exprSnippets(call.Args)...)
+	if call.Ellipsis.IsValid() {
+		expr = expr.WithEllipsis()
+	}
+	return expr
+}
+
+func (c *snippetConverter) funcType(x *ast.FuncType) *FuncType {
+	f := Func(c.fields(x.Params)...)
+	if x.Results != nil {
+		f = f.Return(c.fields(x.Results)...)
+	}
+	return f
+}
+
+func (c *snippetConverter) interfaceType(x *ast.InterfaceType) *InterfaceType {
+	methods := make([]SnippetCanBeInterfaceMethod, 0, len(x.Methods.List))
+
+	for _, m := range x.Methods.List {
+		if ft, ok := m.Type.(*ast.FuncType); ok && len(m.Names) == 1 {
+			f := c.funcType(ft).Named(m.Names[0].Name)
+			if m.Doc != nil {
+				f = f.WithComments(c.commentLines(m.Doc)...)
+			}
+			methods = append(methods, f)
+			continue
+		}
+
+		if method, ok := c.snippetTypeOf(m.Type).(SnippetCanBeInterfaceMethod); ok {
+			methods = append(methods, method)
+			continue
+		}
+
+		methods = append(methods, BuiltInType(c.rawSource(m.Type)))
+	}
+
+	return Interface(methods...)
+}
+
+func (c *snippetConverter) unionTerms(x ast.Expr) []SnippetType {
+	if b, ok := x.(*ast.BinaryExpr); ok && b.Op == token.OR {
+		return append(c.unionTerms(b.X), c.unionTerms(b.Y)...)
+	}
+	return []SnippetType{c.snippetTypeOf(x)}
+}
+
+func (c *snippetConverter) fields(list *ast.FieldList) []*SnippetField {
+	if list == nil {
+		return nil
+	}
+	fields := make([]*SnippetField, 0, len(list.List))
+	for _, f := range list.List {
+		fields = append(fields, c.field(f))
+	}
+	return fields
+}
+
+func (c *snippetConverter) field(f *ast.Field) *SnippetField {
+	sf := &SnippetField{Type: c.snippetTypeOf(f.Type), Names: idents(f.Names...)}
+	if f.Tag != nil {
+		if tag, err := strconv.Unquote(f.Tag.Value); err == nil {
+			sf = sf.WithTag(tag)
+		}
+	}
+	if f.Doc != nil {
+		sf = sf.WithComments(c.commentLines(f.Doc)...)
+	}
+	return sf
+}
+
+func idents(names ...*ast.Ident) []*SnippetIdent {
+	ids := make([]*SnippetIdent, len(names))
+	for i := range names {
+		id := SnippetIdent(names[i].Name)
+		ids[i] = &id
+	}
+	return ids
+}
+
+func (c *snippetConverter) commentLines(cg *ast.CommentGroup) []string {
+	c.used[cg] = true
+
+	lines := make([]string, 0, len(cg.List))
+	for _, c := range cg.List {
+		if strings.HasPrefix(c.Text, "//") {
+			lines = append(lines, strings.TrimPrefix(strings.TrimPrefix(c.Text, "//"), " "))
+			continue
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(strings.TrimPrefix(c.Text, "/*"), "*/"), "\n") {
+			lines = append(lines, strings.TrimSpace(line))
+		}
+	}
+	return lines
+}
+
+func isBuiltInType(name string) bool {
+	switch name {
+	case "bool", "byte", "rune", "string", "error", "any", "comparable",
+		"int", "int8", "int16", "int32", "int64",
+		"uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+		"float32", "float64", "complex64", "complex128":
+		return true
+	}
+	return false
+}
+
+func (c *snippetConverter) commentsBetween(from token.Pos, to token.Pos) []Snippet {
+	snippets := make([]Snippet, 0)
+	for _, cg := range c.comments {
+		if cg.Pos() >= from && cg.End() <= to && !c.used[cg] {
+			snippets = append(snippets, Comments(c.commentLines(cg)...))
+		}
+	}
+	return snippets
+}
+
+func (c *snippetConverter) hasUnusedComments(from token.Pos, to token.Pos) bool {
+	for _, cg := range c.comments {
+		if cg.Pos() >= from && cg.End() <= to && !c.used[cg] {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *snippetConverter) rawSnippet(node ast.Node) Snippet {
+	return SnippetExpr(c.rawSource(node))
+}
+
+func (c *snippetConverter) rawSource(node ast.Node) string {
+	start := node.Pos()
+	switch d := node.(type) {
+	case *ast.FuncDecl:
+		if d.Doc != nil {
+			start = d.Doc.Pos()
+		}
+	case *ast.GenDecl:
+		if d.Doc != nil {
+			start = d.Doc.Pos()
+		}
+	}
+
+	comments := make([]*ast.CommentGroup, 0)
+	for _, cg := range c.comments {
+		if cg.Pos() >= start && cg.End() <= node.End() {
+			c.used[cg] = true
+			comments = append(comments, cg)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := format.Node(buf, c.fset, &printer.CommentedNode{Node: node, Comments: comments}); err != nil {
+		if c.err == nil {
+			c.err = fmt.Errorf("convert %T to snippet: %w", node, err)
+		}
+		return ""
+	}
+	return buf.String()
+}
@@ -0,0 +1,221 @@
+package codegen
+
+import (
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSnippets(t *testing.T) {
+	tt := require.New(t)
+
+	src := `package p
+
+import (
+	"fmt"
+	s "strings" // strings
+)
+
+// Values
+const (
+	A int = iota
+	B
+)
+
+var x, y = 1, "y"
+
+// Map of values
+type Map[K comparable, V any] map[K]V
+
+type Alias = Map[string, int]
+
+type Number interface {
+	~int | ~float64
+}
+
+type Struct struct {
+	// Name of struct
+	Name   string ` + "`json:\"name\"`" + `
+	Values []*Map[string, int]
+	fmt.Stringer
+}
+
+// String returns name
+func (v *Struct) String() string {
+	if v == nil {
+		return ""
+	} else if n := len(v.Name); n > 0 {
+		return s.ToUpper(v.Name)
+	} else {
+		return "-"
+	}
+}
+
+func Do(values ...interface{}) (n int, err error) {
+	for i := 0; i < 10; i++ {
+		if i > 5 {
+			break
+		}
+		continue
+	}
+	for k, v := range values {
+		fmt.Println(k, v)
+	}
+	switch n {
+	case 1, 2:
+		fallthrough
+	default:
+		n = 3
+	}
+	switch x := values[0].(type) {
+	case string:
+		_ = x
+	}
+	defer func() {
+		fmt.Println(&Struct{Name: "1"})
+	}()
+	go fmt.Println(values...)
+	return n + 1, nil
+}
+`
+
+	snippets, err := ParseSnippets(src)
+	tt.NoError(err)
+
+	parts := make([]string, 0, len(snippets))
+	for _, snippet := range snippets {
+		parts = append(parts, Stringify(snippet))
+	}
+
+	formatted, err := format.Source([]byte("package p\n\n" + strings.Join(parts, "\n\n") + "\n"))
+	tt.NoError(err)
+	tt.Equal(src, string(formatted))
+}
+
+func TestParseSnippets_Fragments(t *testing.T) {
+	tt := require.New(t)
+
+	snippets, err := ParseSnippets(`func Fn() {}`)
+	tt.NoError(err)
+	tt.Equal(`func Fn() {
+}`, Stringify(snippets[0]))
+
+	snippets, err = ParseSnippets(`a := 1
+if a > 0 {
+	a++
+}`)
+	tt.NoError(err)
+	tt.Len(snippets, 2)
+	tt.IsType(&SnippetAssignStmt{}, snippets[0])
+	tt.IsType(&SnippetIfStmt{}, snippets[1])
+
+	snippets, err = ParseSnippets(`fmt.Sprintf("%d", 1)`)
+	tt.NoError(err)
+	tt.IsType(&SnippetCallExpr{}, snippets[0])
+
+	_, err = ParseSnippets(`func {`)
+	tt.Error(err)
+}
+
+func TestSnippetOf(t *testing.T) {
+	tt := require.New(t)
+
+	expr, err := parser.ParseExpr(`a[1] + b`)
+	tt.NoError(err)
+	snippet, err := SnippetOf(expr)
+	tt.NoError(err)
+	tt.Equal(SnippetExpr("a[1] + b"), snippet)
+
+	expr, err = parser.ParseExpr(`[]map[string]*T{}`)
+	tt.NoError(err)
+	snippet, err = SnippetOf(expr)
+	tt.NoError(err)
+	tt.Equal("[]map[string]*T{\n}", Stringify(snippet))
+
+	tpe, err := SnippetTypeOf(expr.(*ast.CompositeLit).Type)
+	tt.NoError(err)
+	tt.Equal(Slice(Map(String, Star(Type("T")))), tpe)
+
+	snippet, err = SnippetOf(&ast.Field{Names: []*ast.Ident{ast.NewIdent("a")}, Type: ast.NewIdent("int")})
+	tt.NoError(err)
+	tt.Equal("a int", Stringify(snippet))
+
+	_, err = SnippetOf(&ast.FieldList{})
+	tt.Error(err)
+}
+
+func TestParseSnippets_Comments(t *testing.T) {
+	tt := require.New(t)
+
+	src := `package p
+
+// Do does
+func Do(v int) {
+	// keep
+	a := v
+
+	switch a {
+	case 1:
+		// one
+		a++
+	default:
+		// nothing
+	}
+
+	fmt.Println(a /* inline */, 1)
+	// tail
+}
+
+// S is kept as is
+type S struct {
+	A int // a
+}
+
+// floating
+`
+
+	snippets, err := ParseSnippets(src)
+	tt.NoError(err)
+
+	parts := make([]string, 0, len(snippets))
+	for _, snippet := range snippets {
+		parts = append(parts, Stringify(snippet))
+	}
+
+	formatted, err := format.Source([]byte("package p\n\n" + strings.Join(parts, "\n\n") + "\n"))
+	tt.NoError(err)
+	tt.Equal(`package p
+
+// Do does
+func Do(v int) {
+	// keep
+	a := v
+	switch a {
+	case 1:
+		// one
+		a++
+	default:
+		// nothing
+	}
+	fmt.Println(a /* inline */, 1)
+	// tail
+}
+
+// S is kept as is
+type S struct {
+	A int // a
+}
+
+// floating
+`, string(formatted))
+
+	snippets, err = ParseSnippets(`// keep
+a := 1`)
+	tt.NoError(err)
+	tt.Equal(Comments("keep"), snippets[0])
+	tt.IsType(&SnippetAssignStmt{}, snippets[1])
+}
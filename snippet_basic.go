@@ -32,16 +32,24 @@ func (body Body) Bytes() []byte {
 		if stmt == nil {
 			continue
 		}
-		buf.WriteRune('\n')
+		writeLineBreak(buf)
 		buf.Write(stmt.Bytes())
 	}
 
-	buf.WriteRune('\n')
+	writeLineBreak(buf)
 	buf.WriteRune('}')
 
 	return buf.Bytes()
 }
 
+// writeLineBreak starts a new line unless the previous snippet, like SnippetComments, already ended one
+func writeLineBreak(buf *bytes.Buffer) {
+	if data := buf.Bytes(); len(data) > 0 && data[len(data)-1] == '\n' {
+		return
+	}
+	buf.WriteRune('\n')
+}
+
 type SnippetBuiltIn string
 
 func (tpe SnippetBuiltIn) Bytes() []byte {
@@ -37,11 +37,21 @@ func DeclType(specs ...SnippetSpec) *SnippetTypeDecl {
 type SnippetTypeDecl struct {
 	Token token.Token
 	Specs []SnippetSpec
+	SnippetComments
+}
+
+func (decl SnippetTypeDecl) WithComments(comments ...string) *SnippetTypeDecl {
+	decl.SnippetComments = Comments(comments...)
+	return &decl
 }
 
 func (decl *SnippetTypeDecl) Bytes() []byte {
 	buf := &bytes.Buffer{}
 
+	if decl.SnippetComments != nil {
+		buf.Write(decl.SnippetComments.Bytes())
+	}
+
 	buf.WriteString(decl.Token.String())
 	buf.WriteRune(' ')
 
@@ -77,10 +87,11 @@ func Var(tpe SnippetType, names ...string) *SnippetField {
 type SnippetField struct {
 	SnippetSpec
 	SnippetCanAddr
-	Type  SnippetType
-	Names []*SnippetIdent
-	Tag   string
-	Alias bool
+	Type       SnippetType
+	Names      []*SnippetIdent
+	TypeParams []*SnippetField
+	Tag        string
+	Alias      bool
 	SnippetComments
 }
 
@@ -89,6 +100,11 @@ func (f SnippetField) AsAlias() *SnippetField {
 	return &f
 }
 
+func (f SnippetField) WithTypeParams(typeParams ...*SnippetField) *SnippetField {
+	f.TypeParams = typeParams
+	return &f
+}
+
 func (f SnippetField) WithComments(comments ...string) *SnippetField {
 	f.SnippetComments = Comments(comments...)
 	return &f
@@ -148,6 +164,8 @@ func (f *SnippetField) Bytes() []byte {
 		buf.Write(f.Names[i].Bytes())
 	}
 
+	writeTypeParams(buf, f.TypeParams)
+
 	if len(f.Names) > 0 {
 		if f.Alias {
 			buf.WriteString(" = ")
@@ -167,3 +185,62 @@ func (f *SnippetField) Bytes() []byte {
 
 	return buf.Bytes()
 }
+
+func Import(importPath string) *SnippetImport {
+	return &SnippetImport{
+		Path: importPath,
+	}
+}
+
+type SnippetImport struct {
+	SnippetSpec
+	Path        string
+	Alias       string
+	LineComment string
+	SnippetComments
+}
+
+func (spec SnippetImport) As(alias string) *SnippetImport {
+	spec.Alias = alias
+	return &spec
+}
+
+func (spec SnippetImport) AsBlank() *SnippetImport {
+	return spec.As("_")
+}
+
+func (spec SnippetImport) AsDot() *SnippetImport {
+	return spec.As(".")
+}
+
+func (spec SnippetImport) WithComments(comments ...string) *SnippetImport {
+	spec.SnippetComments = Comments(comments...)
+	return &spec
+}
+
+func (spec SnippetImport) WithLineComment(comment string) *SnippetImport {
+	spec.LineComment = comment
+	return &spec
+}
+
+func (spec *SnippetImport) Bytes() []byte {
+	buf := &bytes.Buffer{}
+
+	if spec.SnippetComments != nil {
+		buf.Write(spec.SnippetComments.Bytes())
+	}
+
+	if spec.Alias != "" {
+		buf.WriteString(spec.Alias)
+		buf.WriteRune(' ')
+	}
+
+	buf.WriteString(strconv.Quote(spec.Path))
+
+	if spec.LineComment != "" {
+		buf.WriteString(" // ")
+		buf.WriteString(spec.LineComment)
+	}
+
+	return buf.Bytes()
+}
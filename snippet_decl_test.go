@@ -64,3 +64,43 @@ N = time.Time
 		),
 	))
 }
+
+func TestDeclType_WithTypeParams(t *testing.T) {
+	tt := require.New(t)
+
+	tt.Equal("type List[T any] []T", Stringify(
+		DeclType(
+			Var(Slice(Type("T")), "List").WithTypeParams(Var(Any, "T")),
+		),
+	))
+
+	tt.Equal(`type Pair[K comparable, V any] struct {
+Key K
+Value V
+}`, Stringify(
+		DeclType(
+			Var(Struct(
+				Var(Type("K"), "Key"),
+				Var(Type("V"), "Value"),
+			), "Pair").WithTypeParams(Var(Comparable, "K"), Var(Any, "V")),
+		),
+	))
+
+	tt.Equal("type Strings = List[string]", Stringify(
+		DeclType(
+			Var(Instance(Type("List"), String), "Strings").AsAlias(),
+		),
+	))
+}
+
+func TestImport(t *testing.T) {
+	tt := require.New(t)
+
+	tt.Equal(`"fmt"`, Stringify(Import("fmt")))
+	tt.Equal(`_ "embed"`, Stringify(Import("embed").AsBlank()))
+	tt.Equal(`. "math"`, Stringify(Import("math").AsDot()))
+	tt.Equal(`// driver
+pg "github.com/lib/pq" // postgres`, Stringify(
+		Import("github.com/lib/pq").As("pg").WithComments("driver").WithLineComment("postgres"),
+	))
+}
@@ -146,6 +146,9 @@ var reserved = append([]string{
 	Stringify(Rune),
 
 	string(Error),
+
+	Stringify(Any),
+	Stringify(Comparable),
 }, builtInFuncs...)
 
 func (id SnippetIdent) UpperCamelCase() *SnippetIdent {
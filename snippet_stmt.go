@@ -114,11 +114,11 @@ func (stmt *SnippetClause) Bytes() []byte {
 	buf.WriteRune(':')
 
 	for _, s := range stmt.Body {
-		buf.WriteRune('\n')
+		writeLineBreak(buf)
 		buf.Write(s.Bytes())
 	}
 
-	buf.WriteRune('\n')
+	writeLineBreak(buf)
 
 	return buf.Bytes()
 }
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"go/token"
 	"reflect"
+	"regexp"
+	"strings"
 )
 
 type SnippetType interface {
@@ -19,7 +21,16 @@ var TypeOf = createTypeOf(LowerSnakeCase)
 func createTypeOf(aliaser ImportPathAliaser) func(tpe reflect.Type) SnippetType {
 	return func(tpe reflect.Type) SnippetType {
 		if tpe.PkgPath() != "" {
-			return Type(aliaser(tpe.PkgPath()) + "." + tpe.Name())
+			name, typeArgs := splitTypeArgs(tpe.Name())
+			named := Type(qualified(aliaser(tpe.PkgPath()), name))
+			if len(typeArgs) == 0 {
+				return named
+			}
+			args := make([]SnippetType, len(typeArgs))
+			for i := range typeArgs {
+				args[i] = BuiltInType(qualifyTypeArg(aliaser, typeArgs[i]))
+			}
+			return Instance(named, args...)
 		}
 
 		typeof := createTypeOf(aliaser)
@@ -54,6 +65,44 @@ func createTypeOf(aliaser ImportPathAliaser) func(tpe reflect.Type) SnippetType
 	}
 }
 
+func splitTypeArgs(name string) (string, []string) {
+	i := strings.Index(name, "[")
+	if i < 0 || !strings.HasSuffix(name, "]") {
+		return name, nil
+	}
+
+	typeArgs := make([]string, 0)
+
+	inner := name[i+1 : len(name)-1]
+	depth := 0
+	start := 0
+
+	for j, r := range inner {
+		switch r {
+		case '[', '(', '{':
+			depth++
+		case ']', ')', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				typeArgs = append(typeArgs, strings.TrimSpace(inner[start:j]))
+				start = j + 1
+			}
+		}
+	}
+
+	return name[0:i], append(typeArgs, strings.TrimSpace(inner[start:]))
+}
+
+var reQualifiedName = regexp.MustCompile(`([\w./-]+)\.([\p{L}_][\p{L}\p{N}_]*)`)
+
+func qualifyTypeArg(aliaser ImportPathAliaser, typeArg string) string {
+	return reQualifiedName.ReplaceAllStringFunc(typeArg, func(s string) string {
+		parts := reQualifiedName.FindStringSubmatch(s)
+		return qualified(aliaser(parts[1]), parts[2])
+	})
+}
+
 func Ellipsis(tpe SnippetType) *EllipsisType {
 	return &EllipsisType{
 		Elem: tpe,
@@ -111,6 +160,56 @@ func (tpe *NamedType) Bytes() []byte {
 	return tpe.Name.Bytes()
 }
 
+func Instance(tpe SnippetType, typeArgs ...SnippetType) *InstanceType {
+	return &InstanceType{
+		Type:     tpe,
+		TypeArgs: typeArgs,
+	}
+}
+
+type InstanceType struct {
+	SnippetType
+	SnippetCanBeInterfaceMethod
+	SnippetCanAddr
+	Type     SnippetType
+	TypeArgs []SnippetType
+}
+
+func (tpe *InstanceType) Bytes() []byte {
+	buf := &bytes.Buffer{}
+
+	buf.Write(tpe.Type.Bytes())
+	buf.WriteRune('[')
+
+	for i := range tpe.TypeArgs {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.Write(tpe.TypeArgs[i].Bytes())
+	}
+
+	buf.WriteRune(']')
+
+	return buf.Bytes()
+}
+
+func writeTypeParams(buf *bytes.Buffer, typeParams []*SnippetField) {
+	if len(typeParams) == 0 {
+		return
+	}
+
+	buf.WriteRune('[')
+
+	for i := range typeParams {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.Write(typeParams[i].WithoutTag().Bytes())
+	}
+
+	buf.WriteRune(']')
+}
+
 func Func(params ...*SnippetField) *FuncType {
 	return &FuncType{
 		Params: params,
@@ -120,11 +219,13 @@ func Func(params ...*SnippetField) *FuncType {
 type FuncType struct {
 	SnippetType
 	SnippetCanBeInterfaceMethod
-	Name    *SnippetIdent
-	Recv    *SnippetField
-	Params  []*SnippetField
-	Results []*SnippetField
-	Body    []Snippet
+	SnippetComments
+	Name       *SnippetIdent
+	Recv       *SnippetField
+	TypeParams []*SnippetField
+	Params     []*SnippetField
+	Results    []*SnippetField
+	Body       []Snippet
 
 	noFuncToken bool
 }
@@ -134,6 +235,11 @@ func (f FuncType) withoutFuncToken() *FuncType {
 	return &f
 }
 
+func (f FuncType) WithComments(comments ...string) *FuncType {
+	f.SnippetComments = Comments(comments...)
+	return &f
+}
+
 func (f FuncType) Do(bodies ...Snippet) *FuncType {
 	f.Body = append([]Snippet{}, bodies...)
 	return &f
@@ -144,6 +250,11 @@ func (f FuncType) Named(name string) *FuncType {
 	return &f
 }
 
+func (f FuncType) WithTypeParams(typeParams ...*SnippetField) *FuncType {
+	f.TypeParams = typeParams
+	return &f
+}
+
 func (f FuncType) MethodOf(recv *SnippetField) *FuncType {
 	f.Recv = recv
 	return &f
@@ -157,6 +268,10 @@ func (f FuncType) Return(results ...*SnippetField) *FuncType {
 func (f *FuncType) Bytes() []byte {
 	buf := &bytes.Buffer{}
 
+	if f.SnippetComments != nil {
+		buf.Write(f.SnippetComments.Bytes())
+	}
+
 	if !f.noFuncToken {
 		buf.WriteString(token.FUNC.String())
 		buf.WriteRune(' ')
@@ -170,6 +285,7 @@ func (f *FuncType) Bytes() []byte {
 
 	if f.Name != nil {
 		buf.Write(f.Name.Bytes())
+		writeTypeParams(buf, f.TypeParams)
 	}
 
 	buf.WriteByte('(')
@@ -242,6 +358,7 @@ func Interface(methods ...SnippetCanBeInterfaceMethod) *InterfaceType {
 }
 
 type SnippetCanBeInterfaceMethod interface {
+	Snippet
 	canBeInterfaceMethod()
 }
 
@@ -259,12 +376,11 @@ func (tpe *InterfaceType) Bytes() []byte {
 		if i == 0 {
 			buf.WriteRune('\n')
 		}
-		methodType := tpe.Methods[i]
-		switch methodType.(type) {
+		switch methodType := tpe.Methods[i].(type) {
 		case *FuncType:
-			buf.Write(methodType.(*FuncType).withoutFuncToken().Bytes())
-		case *NamedType:
-			buf.Write(methodType.(*NamedType).Bytes())
+			buf.Write(methodType.withoutFuncToken().Bytes())
+		default:
+			buf.Write(methodType.Bytes())
 		}
 		buf.WriteRune('\n')
 	}
@@ -274,6 +390,52 @@ func (tpe *InterfaceType) Bytes() []byte {
 	return buf.Bytes()
 }
 
+func Union(terms ...SnippetType) *UnionType {
+	return &UnionType{
+		Terms: terms,
+	}
+}
+
+type UnionType struct {
+	SnippetType
+	SnippetCanBeInterfaceMethod
+	Terms []SnippetType
+}
+
+func (tpe *UnionType) Bytes() []byte {
+	buf := &bytes.Buffer{}
+
+	for i := range tpe.Terms {
+		if i > 0 {
+			buf.WriteString(" " + token.OR.String() + " ")
+		}
+		buf.Write(tpe.Terms[i].Bytes())
+	}
+
+	return buf.Bytes()
+}
+
+func Tilde(tpe SnippetType) *TildeType {
+	return &TildeType{
+		Elem: tpe,
+	}
+}
+
+type TildeType struct {
+	SnippetType
+	SnippetCanBeInterfaceMethod
+	Elem SnippetType
+}
+
+func (tpe *TildeType) Bytes() []byte {
+	buf := &bytes.Buffer{}
+
+	buf.WriteString(token.TILDE.String())
+	buf.Write(tpe.Elem.Bytes())
+
+	return buf.Bytes()
+}
+
 func Map(key SnippetType, value SnippetType) *MapType {
 	return &MapType{
 		Key:   key,
@@ -344,6 +506,8 @@ type BuiltInType string
 
 func (BuiltInType) snippetType() {}
 
+func (BuiltInType) canBeInterfaceMethod() {}
+
 func (tpe BuiltInType) Bytes() []byte {
 	return []byte(string(tpe))
 }
@@ -374,4 +538,7 @@ const (
 	Rune   BuiltInType = "rune"
 
 	Error BuiltInType = "error"
+
+	Any        BuiltInType = "any"
+	Comparable BuiltInType = "comparable"
 )
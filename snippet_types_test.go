@@ -70,6 +70,56 @@ String() (string)
 	)))
 }
 
+func TestSnippetType_InterfaceTypeSet(t *testing.T) {
+	tt := require.New(t)
+
+	tt.Equal("~int | ~string", Stringify(Union(Tilde(Int), Tilde(String))))
+
+	tt.Equal(`interface {
+~int | ~int64 | float64
+}`, Stringify(Interface(
+		Union(Tilde(Int), Tilde(Int64), Float64),
+	)))
+
+	tt.Equal(`interface {
+comparable
+fmt.Stringer
+Set[T]
+}`, Stringify(Interface(
+		Comparable,
+		Type("fmt.Stringer"),
+		Instance(Type("Set"), Type("T")),
+	)))
+
+	tt.Equal("func Sum[T ~int | ~float64](values ...T) (T)", Stringify(
+		Func(Var(Ellipsis(Type("T")), "values")).
+			WithTypeParams(Var(Union(Tilde(Int), Tilde(Float64)), "T")).
+			Return(Var(Type("T"))).
+			Named("Sum"),
+	))
+}
+
+func TestSnippetType_InterfaceTypeWithMethodComments(t *testing.T) {
+	tt := require.New(t)
+
+	tt.Equal(`interface {
+io.Reader
+// Type returns the type name
+Type() (string)
+// Close releases resources
+// held by the value
+Close() (error)
+}`, Stringify(Interface(
+		Type("io.Reader"),
+		Func().Return(Var(String)).Named("Type").WithComments("Type returns the type name"),
+		Func().Return(Var(Error)).Named("Close").WithComments("Close releases resources\nheld by the value"),
+	)))
+
+	tt.Equal(`// Fn does nothing
+func Fn() {
+}`, Stringify(Func().Named("Fn").WithComments("Fn does nothing").Do()))
+}
+
 func TestSnippetType_FuncType(t *testing.T) {
 	tt := require.New(t)
 
@@ -124,3 +174,63 @@ KeyA, KeyA1 bool
 		Var(Bool, "KeyA", "KeyA1"),
 	)))
 }
+
+type genericPair[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+func TestSnippetTypeOf_Instance(t *testing.T) {
+	tt := require.New(t)
+
+	tt.Equal("github_com_go_courier_codegen.genericPair[string, *bytes.Buffer]", Stringify(TypeOf(reflect.TypeOf(genericPair[string, *bytes.Buffer]{}))))
+	tt.Equal(
+		"github_com_go_courier_codegen.genericPair[string, github_com_go_courier_codegen.genericPair[int,map[string]bytes.Buffer]]",
+		Stringify(TypeOf(reflect.TypeOf(genericPair[string, genericPair[int, map[string]bytes.Buffer]]{}))),
+	)
+
+	importPaths := make([]string, 0)
+	typeOf := createTypeOf(func(importPath string) string {
+		importPaths = append(importPaths, importPath)
+		return "pkg"
+	})
+
+	tt.Equal("pkg.genericPair[int, []*pkg.Buffer]", Stringify(typeOf(reflect.TypeOf(genericPair[int, []*bytes.Buffer]{}))))
+	tt.Equal([]string{"github.com/go-courier/codegen", "bytes"}, importPaths)
+}
+
+func TestSnippetType_Instance(t *testing.T) {
+	tt := require.New(t)
+
+	tt.Equal("List[int]", Stringify(Instance(Type("List"), Int)))
+	tt.Equal("Map[K, V]", Stringify(Instance(Type("Map"), Type("K"), Type("V"))))
+	tt.Equal("*list.List[[]time.Time]", Stringify(Star(Instance(Type("list.List"), Slice(Type("time.Time"))))))
+}
+
+func TestSnippetType_FuncTypeWithTypeParams(t *testing.T) {
+	tt := require.New(t)
+
+	tt.Equal("func Map[T any, R any](list []T, fn func (T) (R)) ([]R)", Stringify(
+		Func(
+			Var(Slice(Type("T")), "list"),
+			Var(Func(Var(Type("T"))).Return(Var(Type("R"))), "fn"),
+		).
+			WithTypeParams(Var(Any, "T"), Var(Any, "R")).
+			Return(Var(Slice(Type("R")))).
+			Named("Map"),
+	))
+
+	tt.Equal("func Keys[K comparable, V any](m map[K]V) ([]K)", Stringify(
+		Func(Var(Map(Type("K"), Type("V")), "m")).
+			WithTypeParams(Var(Comparable, "K"), Var(Any, "V")).
+			Return(Var(Slice(Type("K")))).
+			Named("Keys"),
+	))
+
+	tt.Equal("func (l *List[T]) Len() (int)", Stringify(
+		Func().
+			MethodOf(Var(Star(Instance(Type("List"), Type("T"))), "l")).
+			Return(Var(Int)).
+			Named("Len"),
+	))
+}
@@ -0,0 +1,343 @@
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	originMarker    = "/*codegen:origin "
+	originEndMarker = "/*codegen:origin-end*/"
+)
+
+func At(pos token.Position, s Snippet) *SnippetOrigin {
+	return &SnippetOrigin{
+		Snippet: s,
+		Pos:     pos,
+	}
+}
+
+func Here(s Snippet) *SnippetOrigin {
+	_, filename, line, _ := runtime.Caller(1)
+	return At(token.Position{Filename: filename, Line: line, Column: 1}, s)
+}
+
+type SnippetOrigin struct {
+	Snippet
+	Pos token.Position
+}
+
+func (s *SnippetOrigin) Bytes() []byte {
+	buf := &bytes.Buffer{}
+
+	data := s.Snippet.Bytes()
+
+	buf.WriteString(originMarker)
+	buf.WriteString(s.Pos.String())
+	buf.WriteString("*/")
+	buf.Write(data)
+	// the end marker would be swallowed by a trailing line comment
+	if endsWithLineComment(data) {
+		buf.WriteRune('\n')
+	}
+	buf.WriteString(originEndMarker)
+
+	return buf.Bytes()
+}
+
+func endsWithLineComment(src []byte) bool {
+	s := &scanner.Scanner{}
+	s.Init(token.NewFileSet().AddFile("", -1, len(src)), src, nil, scanner.ScanComments)
+
+	last := ""
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.SEMICOLON && lit == "\n" {
+			continue
+		}
+		last = ""
+		if tok == token.COMMENT {
+			last = lit
+		}
+	}
+
+	return strings.HasPrefix(last, "//")
+}
+
+type SourceMapping struct {
+	Line    int            `json:"line"`
+	EndLine int            `json:"endLine"`
+	Origin  token.Position `json:"origin"`
+
+	directives int
+}
+
+type SourceMap []*SourceMapping
+
+func (m SourceMap) Lookup(line int) (token.Position, bool) {
+	for i := len(m) - 1; i >= 0; i-- {
+		if mapping := m[i]; line >= mapping.Line && line <= mapping.EndLine {
+			return mapping.origin(line), true
+		}
+	}
+	return token.Position{}, false
+}
+
+func (mapping *SourceMapping) origin(line int) token.Position {
+	pos := mapping.Origin
+	if line != mapping.Line {
+		pos.Column = 0
+	}
+	pos.Line += line - mapping.Line
+	return pos
+}
+
+// resolveOrigins strips the origin markers, which gofmt may leave on their own line or inline
+// padded by a space, and records where each origin landed in the output.
+func resolveOrigins(filename string, data []byte, lineDirectives bool) ([]byte, SourceMap, error) {
+	sourceMap := SourceMap{}
+	stack := make([]*SourceMapping, 0)
+
+	lines := make([][]byte, 0)
+
+	countDirective := func() {
+		for _, mapping := range stack {
+			mapping.directives++
+		}
+	}
+
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+
+		if !bytes.Contains(line, []byte(originMarker)) && !bytes.Contains(line, []byte(originEndMarker)) {
+			lines = append(lines, line)
+			continue
+		}
+
+		cur := make([]byte, 0, len(line))
+		rest := line
+		after := make([]func(), 0)
+
+		for {
+			i := indexOfMarker(rest)
+			if i < 0 {
+				cur = append(cur, rest...)
+				break
+			}
+
+			cur = append(cur, rest[0:i]...)
+			rest = rest[i:]
+
+			n := bytes.Index(rest, []byte("*/")) + len("*/")
+			marker := string(rest[0:n])
+			rest = rest[n:]
+
+			atLineStart := len(bytes.TrimSpace(cur)) == 0
+			atLineEnd := len(bytes.TrimSpace(stripMarkers(rest))) == 0
+
+			if marker == originEndMarker {
+				if len(stack) == 0 {
+					return nil, nil, fmt.Errorf("unexpected %s", originEndMarker)
+				}
+
+				if k := len(cur); k > 0 && cur[k-1] == ' ' {
+					cur = cur[0 : k-1]
+				} else if len(rest) > 0 && rest[0] == ' ' {
+					rest = rest[1:]
+				}
+
+				mapping := stack[len(stack)-1]
+				stack = stack[0 : len(stack)-1]
+
+				if atLineStart {
+					// gofmt separates a top-level declaration from the following marker by a blank line
+					if k := len(lines); k > 0 && len(bytes.TrimSpace(lines[k-1])) == 0 {
+						lines = lines[0 : k-1]
+					}
+					mapping.EndLine = len(lines)
+				} else {
+					mapping.EndLine = len(lines) + 1
+				}
+
+				if !lineDirectives {
+					continue
+				}
+
+				var outer *SourceMapping
+				if len(stack) > 0 {
+					outer = stack[len(stack)-1]
+				}
+
+				if atLineEnd {
+					after = append(after, func() {
+						next := len(lines) + 2
+
+						if outer != nil {
+							lines = append(lines, []byte(lineDirective(outer.origin(next-outer.directives-1))))
+						} else {
+							lines = append(lines, []byte(lineDirective(token.Position{Filename: filepath.Base(filename), Line: next, Column: 1})))
+						}
+						countDirective()
+					})
+					continue
+				}
+
+				if outer != nil {
+					pos := outer.origin(len(lines) + 1 - outer.directives)
+					pos.Column = 0
+					cur = append(cur, inlineLineDirective(pos)...)
+				} else {
+					cur = append(cur, inlineRestoreDirective(filepath.Base(filename), len(lines)+1, len(cur))...)
+				}
+				continue
+			}
+
+			pos, err := parsePosition(strings.TrimSuffix(strings.TrimPrefix(marker, originMarker), "*/"))
+			if err != nil {
+				return nil, nil, err
+			}
+
+			switch {
+			case len(rest) > 0 && rest[0] == ',':
+				// gofmt moves a comment placed after a comma in front of it
+				cur = append(bytes.TrimRight(cur, " "), ", "...)
+				rest = bytes.TrimLeft(rest[1:], " ")
+			case len(rest) > 0 && rest[0] == ' ':
+				rest = rest[1:]
+			case len(cur) > 0 && cur[len(cur)-1] == ' ':
+				cur = cur[0 : len(cur)-1]
+			}
+
+			if lineDirectives {
+				if atLineStart {
+					lines = append(lines, []byte(lineDirective(pos)))
+					countDirective()
+				} else {
+					cur = append(cur, inlineLineDirective(pos)...)
+				}
+			}
+
+			mapping := &SourceMapping{Origin: pos, Line: len(lines) + 1}
+			sourceMap = append(sourceMap, mapping)
+			stack = append(stack, mapping)
+		}
+
+		if len(bytes.TrimSpace(cur)) > 0 {
+			lines = append(lines, cur)
+		}
+
+		for _, fn := range after {
+			fn()
+		}
+	}
+
+	if len(stack) > 0 {
+		return nil, nil, fmt.Errorf("missing %s for origin %s", originEndMarker, stack[len(stack)-1].Origin)
+	}
+
+	return bytes.Join(lines, nil), sourceMap, nil
+}
+
+func indexOfMarker(src []byte) int {
+	i := bytes.Index(src, []byte(originMarker))
+	if j := bytes.Index(src, []byte(originEndMarker)); j >= 0 && (i < 0 || j < i) {
+		return j
+	}
+	return i
+}
+
+func stripMarkers(src []byte) []byte {
+	stripped := make([]byte, 0, len(src))
+	for {
+		i := indexOfMarker(src)
+		if i < 0 {
+			return append(stripped, src...)
+		}
+		stripped = append(stripped, src[0:i]...)
+		src = src[i+bytes.Index(src[i:], []byte("*/"))+len("*/"):]
+	}
+}
+
+func originOfLine(src []byte, line int) (token.Position, bool) {
+	_, sourceMap, err := resolveOrigins("", src, false)
+	if err != nil {
+		return token.Position{}, false
+	}
+
+	lines := bytes.Split(src, []byte("\n"))
+	removed := 0
+
+	for i := 0; i < line-1 && i < len(lines); i++ {
+		if indexOfMarker(lines[i]) >= 0 && len(bytes.TrimSpace(stripMarkers(lines[i]))) == 0 {
+			removed++
+		}
+	}
+
+	return sourceMap.Lookup(line - removed)
+}
+
+func lineDirective(pos token.Position) string {
+	if pos.Column > 0 {
+		return fmt.Sprintf("//line %s:%d:%d\n", pos.Filename, pos.Line, pos.Column)
+	}
+	return fmt.Sprintf("//line %s:%d\n", pos.Filename, pos.Line)
+}
+
+func inlineLineDirective(pos token.Position) string {
+	if pos.Column > 0 {
+		return fmt.Sprintf("/*line %s:%d:%d*/", pos.Filename, pos.Line, pos.Column)
+	}
+	return fmt.Sprintf("/*line %s:%d*/", pos.Filename, pos.Line)
+}
+
+// inlineRestoreDirective points the character following it back to its own column in the output
+func inlineRestoreDirective(filename string, line int, offset int) string {
+	pos := token.Position{Filename: filename, Line: line, Column: offset + 1}
+	for {
+		directive := inlineLineDirective(pos)
+		if column := offset + len(directive) + 1; column != pos.Column {
+			pos.Column = column
+			continue
+		}
+		return directive
+	}
+}
+
+func parsePosition(s string) (token.Position, error) {
+	pos := token.Position{}
+
+	parts := strings.Split(s, ":")
+
+	numbers := make([]int, 0, 2)
+	for len(parts) > 1 && len(numbers) < 2 {
+		n, err := strconv.Atoi(parts[len(parts)-1])
+		if err != nil {
+			break
+		}
+		numbers = append([]int{n}, numbers...)
+		parts = parts[0 : len(parts)-1]
+	}
+
+	if len(numbers) == 0 {
+		return pos, fmt.Errorf("invalid origin position `%s`", s)
+	}
+
+	pos.Filename = strings.Join(parts, ":")
+	pos.Line = numbers[0]
+	if len(numbers) > 1 {
+		pos.Column = numbers[1]
+	}
+
+	return pos, nil
+}
@@ -0,0 +1,189 @@
+package codegen
+
+import (
+	"errors"
+	"go/ast"
+	"go/token"
+	"testing"
+
+	"github.com/go-courier/codegen/formatx"
+	"github.com/stretchr/testify/require"
+)
+
+func newOriginFile() *File {
+	file := NewFile("main", "main.go")
+
+	file.WriteBlock(
+		At(token.Position{Filename: "schema.yaml", Line: 10, Column: 3}, Func().Named("Get").Do(
+			Expr("x := 1"),
+			At(token.Position{Filename: "template.tmpl", Line: 5}, Expr("_ = x")),
+		)),
+		Func().Named("main").Do(),
+	)
+
+	return file
+}
+
+func TestFile_RenderWithSourceMap(t *testing.T) {
+	t.Run("source map", func(t *testing.T) {
+		tt := require.New(t)
+
+		data, sourceMap, err := newOriginFile().RenderWithSourceMap()
+		tt.NoError(err)
+		tt.Equal(`package main
+
+func Get() {
+	x := 1
+	_ = x
+}
+
+func main() {
+}
+`, string(data))
+
+		tt.Equal(SourceMap{
+			{Line: 3, EndLine: 6, Origin: token.Position{Filename: "schema.yaml", Line: 10, Column: 3}},
+			{Line: 5, EndLine: 5, Origin: token.Position{Filename: "template.tmpl", Line: 5}},
+		}, sourceMap)
+
+		pos, ok := sourceMap.Lookup(4)
+		tt.True(ok)
+		tt.Equal(token.Position{Filename: "schema.yaml", Line: 11}, pos)
+
+		pos, ok = sourceMap.Lookup(5)
+		tt.True(ok)
+		tt.Equal(token.Position{Filename: "template.tmpl", Line: 5}, pos)
+
+		_, ok = sourceMap.Lookup(8)
+		tt.False(ok)
+	})
+
+	t.Run("line directives", func(t *testing.T) {
+		tt := require.New(t)
+
+		data, err := newOriginFile().WithLineDirectives(true).Render()
+		tt.NoError(err)
+		tt.Equal(`package main
+
+//line schema.yaml:10:3
+func Get() {
+	x := 1
+//line template.tmpl:5
+	_ = x
+//line schema.yaml:13
+}
+//line main.go:11:1
+
+func main() {
+}
+`, string(data))
+
+		fset, f, err := formatx.ParseFile("main.go", data)
+		tt.NoError(err)
+		tt.Equal("schema.yaml:10:3", fset.Position(f.Decls[0].Pos()).String())
+		tt.Equal("main.go:12:1", fset.Position(f.Decls[1].Pos()).String())
+	})
+
+	t.Run("format error", func(t *testing.T) {
+		tt := require.New(t)
+
+		file := NewFile("main", "main.go")
+		file.WriteBlock(
+			At(token.Position{Filename: "schema.yaml", Line: 10}, Func().Named("Get").Do(
+				Expr("x := )"),
+			)),
+		)
+
+		_, err := file.Render()
+
+		fileErr := &FileError{}
+		tt.True(errors.As(err, &fileErr))
+		tt.Equal(token.Position{Filename: "schema.yaml", Line: 11}, fileErr.Origin)
+	})
+}
+
+func TestFile_RenderWithSourceMap_Expr(t *testing.T) {
+	newFile := func() *File {
+		file := NewFile("main", "main.go")
+		file.WriteBlock(
+			Func().Named("main").Do(
+				Call("println", Val(1), At(token.Position{Filename: "schema.yaml", Line: 3, Column: 7}, Id("x"))),
+				At(token.Position{Filename: "schema.yaml", Line: 9}, DeclVar(Assign(Var(Int, "y")))),
+			),
+		)
+		return file
+	}
+
+	t.Run("source map", func(t *testing.T) {
+		tt := require.New(t)
+
+		data, sourceMap, err := newFile().RenderWithSourceMap()
+		tt.NoError(err)
+		tt.Equal(`package main
+
+func main() {
+	println(1, x)
+	var y int
+}
+`, string(data))
+
+		tt.Equal(SourceMap{
+			{Line: 4, EndLine: 4, Origin: token.Position{Filename: "schema.yaml", Line: 3, Column: 7}},
+			{Line: 5, EndLine: 5, Origin: token.Position{Filename: "schema.yaml", Line: 9}},
+		}, sourceMap)
+	})
+
+	t.Run("line directives", func(t *testing.T) {
+		tt := require.New(t)
+
+		data, err := newFile().WithLineDirectives(true).Render()
+		tt.NoError(err)
+		tt.Equal(`package main
+
+func main() {
+	println(1, /*line schema.yaml:3:7*/x/*line main.go:4:59*/)
+//line schema.yaml:9
+	var y int
+//line main.go:8:1
+}
+`, string(data))
+
+		fset, f, err := formatx.ParseFile("main.go", data)
+		tt.NoError(err)
+
+		call := f.Decls[0].(*ast.FuncDecl).Body.List[0].(*ast.ExprStmt).X.(*ast.CallExpr)
+		tt.Equal("schema.yaml:3:7", fset.Position(call.Args[1].Pos()).String())
+		tt.Equal("main.go:4:59", fset.Position(call.Rparen).String())
+	})
+}
+
+func TestHere(t *testing.T) {
+	tt := require.New(t)
+
+	s := Here(Expr("x := 1"))
+	tt.Contains(s.Pos.Filename, "source_map_test.go")
+	tt.Equal(1, s.Pos.Column)
+}
+
+func TestEndsWithLineComment(t *testing.T) {
+	tt := require.New(t)
+
+	tt.True(endsWithLineComment([]byte("x := 1 // one\n")))
+	tt.False(endsWithLineComment([]byte(`x := "http://"`)))
+	tt.False(endsWithLineComment([]byte("// one\nx := 1")))
+}
+
+func TestParsePosition(t *testing.T) {
+	tt := require.New(t)
+
+	pos, err := parsePosition("C:/schemas/a.yaml:10:3")
+	tt.NoError(err)
+	tt.Equal(token.Position{Filename: "C:/schemas/a.yaml", Line: 10, Column: 3}, pos)
+
+	pos, err = parsePosition("a.tmpl:7")
+	tt.NoError(err)
+	tt.Equal(token.Position{Filename: "a.tmpl", Line: 7}, pos)
+
+	_, err = parsePosition("a.tmpl")
+	tt.Error(err)
+}
@@ -0,0 +1,154 @@
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"golang.org/x/tools/go/packages"
+)
+
+type Diagnostic struct {
+	Filename string
+	Line     int
+	Column   int
+	Msg      string
+	Origin   token.Position
+}
+
+func (d *Diagnostic) String() string {
+	if d.Filename == "" {
+		return d.Msg
+	}
+	if d.Origin.IsValid() {
+		return fmt.Sprintf("%s:%d:%d: %s (from %s)", d.Filename, d.Line, d.Column, d.Msg, d.Origin)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", d.Filename, d.Line, d.Column, d.Msg)
+}
+
+type TypeCheckError struct {
+	Diagnostics []*Diagnostic
+}
+
+func (e *TypeCheckError) Error() string {
+	buf := &bytes.Buffer{}
+
+	buf.WriteString("type check failed:")
+
+	for _, d := range e.Diagnostics {
+		buf.WriteString("\n\t")
+		buf.WriteString(d.String())
+	}
+
+	return buf.String()
+}
+
+func TypeCheck(files ...*File) ([]*Diagnostic, error) {
+	overlays := map[string]map[string][]byte{}
+	sourceMaps := map[string]SourceMap{}
+	withTests := map[string]bool{}
+
+	for _, file := range files {
+		data, sourceMap, err := file.RenderWithSourceMap()
+		if err != nil {
+			return nil, err
+		}
+
+		filename, err := filepath.Abs(file.filename)
+		if err != nil {
+			return nil, err
+		}
+
+		dir := filepath.Dir(filename)
+		if overlays[dir] == nil {
+			overlays[dir] = map[string][]byte{}
+		}
+		overlays[dir][filename] = data
+		sourceMaps[filename] = sourceMap
+		withTests[dir] = withTests[dir] || IsGoTestFile(filename)
+	}
+
+	dirs := make([]string, 0, len(overlays))
+	for dir := range overlays {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	diagnostics := make([]*Diagnostic, 0)
+	seen := map[string]bool{}
+
+	for _, dir := range dirs {
+		filenames := make([]string, 0, len(overlays[dir]))
+		for filename := range overlays[dir] {
+			filenames = append(filenames, filename)
+		}
+		sort.Strings(filenames)
+
+		pkgs, err := packages.Load(&packages.Config{
+			Mode:    packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedTypes,
+			Dir:     dir,
+			Tests:   withTests[dir],
+			Overlay: overlays[dir],
+		}, "file="+filenames[0])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, d := range diagnosticsOf(pkgs) {
+			if key := d.String(); !seen[key] {
+				seen[key] = true
+				if origin, ok := sourceMaps[d.Filename].Lookup(d.Line); ok {
+					d.Origin = origin
+				}
+				diagnostics = append(diagnostics, d)
+			}
+		}
+	}
+
+	return diagnostics, nil
+}
+
+func typeCheckFiles(files ...*File) error {
+	diagnostics, err := TypeCheck(files...)
+	if err != nil {
+		return err
+	}
+	if len(diagnostics) > 0 {
+		return &TypeCheckError{Diagnostics: diagnostics}
+	}
+	return nil
+}
+
+func diagnosticsOf(pkgs []*packages.Package) []*Diagnostic {
+	listErrors := make([]*Diagnostic, 0)
+	diagnostics := make([]*Diagnostic, 0)
+
+	for _, pkg := range pkgs {
+		for _, e := range pkg.Errors {
+			d := &Diagnostic{Msg: e.Msg}
+
+			if matched := rePosition.FindStringSubmatch(e.Pos); matched != nil {
+				d.Filename = matched[1]
+				d.Line, _ = strconv.Atoi(matched[2])
+				d.Column, _ = strconv.Atoi(matched[3])
+			}
+
+			if e.Kind == packages.ListError {
+				listErrors = append(listErrors, d)
+				continue
+			}
+			diagnostics = append(diagnostics, d)
+		}
+	}
+
+	if len(diagnostics) == 0 {
+		return listErrors
+	}
+	return diagnostics
+}
+
+var rePosition = regexp.MustCompile(`^(.+?):(\d+)(?::(\d+))?$`)
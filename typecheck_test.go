@@ -0,0 +1,56 @@
+package codegen
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypeCheck(t *testing.T) {
+	t.Run("passed", func(t *testing.T) {
+		tt := require.New(t)
+
+		file := NewFile("codegen", "zz__typecheck.go")
+		file.WriteBlock(
+			Func().Named("zzTypeCheck").Return(Var(Star(Type("SnippetIdent")))).Do(
+				Return(Call("Id", file.Val("name"))),
+			),
+		)
+
+		diagnostics, err := TypeCheck(file)
+		tt.NoError(err)
+		tt.Empty(diagnostics)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		tt := require.New(t)
+
+		file := NewFile("codegen", "zz__typecheck.go").WithTypeCheck(true)
+		file.WriteBlock(
+			Func().Named("zzTypeCheck").Return(Var(Star(Type("SnippetIdent")))).Do(
+				Call(file.Use("fmt", "Println"), Id("undefinedValue")),
+				Return(Call("Id", file.Val(1))),
+			),
+		)
+
+		filename, _ := filepath.Abs("zz__typecheck.go")
+
+		diagnostics, err := TypeCheck(file)
+		tt.NoError(err)
+		tt.Equal([]*Diagnostic{
+			{Filename: filename, Line: 8, Column: 14, Msg: "undefined: undefinedValue"},
+			{Filename: filename, Line: 9, Column: 12, Msg: "cannot use 1 (untyped int constant) as string value in argument to Id"},
+		}, diagnostics)
+
+		_, err = file.WriteFile()
+		typeCheckErr := &TypeCheckError{}
+		tt.True(errors.As(err, &typeCheckErr))
+		tt.Len(typeCheckErr.Diagnostics, 2)
+
+		_, statErr := os.Stat(filename)
+		tt.True(os.IsNotExist(statErr))
+	})
+}
@@ -15,6 +15,11 @@ func IsGoTestFile(filename string) bool {
 	return strings.HasSuffix(filepath.Base(filename), "_test.go")
 }
 
+func IsGeneratedFile(filename string) bool {
+	base := filepath.Base(filename)
+	return strings.HasSuffix(base, "__generated.go") || strings.HasSuffix(base, "__generated_test.go")
+}
+
 func GeneratedFileSuffix(filename string) string {
 	dir := filepath.Dir(filename)
 	base := filepath.Base(filename)
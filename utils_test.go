@@ -25,7 +25,11 @@ func TestGeneratedFileSuffix(t *testing.T) {
 
 	for _, c := range cases {
 		require.Equal(t, GeneratedFileSuffix(c.from), c.to)
+		require.True(t, IsGeneratedFile(c.to))
+		require.False(t, IsGeneratedFile(c.from))
 	}
+
+	require.False(t, IsGeneratedFile("./main__generated_helpers.go"))
 }
 
 func TestIsEmptyValue(t *testing.T) {